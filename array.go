@@ -0,0 +1,69 @@
+package chess_engine
+
+import "fmt"
+
+// The piece codes used by ToArray and GameFromArray, so that the board can
+// be shared with code that doesn't know about Pieces. White pieces are
+// positive, black pieces are negative and empty squares are 0:
+//
+//	pawn 1, knight 2, bishop 3, rook 4, queen 5, king 6
+var arrayPieceCodes = map[NormalizedPiece]int8{
+	Pawn:   1,
+	Knight: 2,
+	Bishop: 3,
+	Rook:   4,
+	Queen:  5,
+	King:   6,
+}
+
+// Returns the board as piece codes (see arrayPieceCodes), with a1 at index 0
+// and h8 at index 63.
+func (f *Game) ToArray() [64]int8 {
+	result := [64]int8{}
+	for pos, piece := range f.Board {
+		if piece == NoPiece {
+			continue
+		}
+		code := arrayPieceCodes[piece.ToNormalizedPiece()]
+		if piece.Color() == Black {
+			code = -code
+		}
+		result[pos] = code
+	}
+	return result
+}
+
+// Builds a game from a board returned by ToArray. Nobody can castle and
+// there is no en passant square.
+func GameFromArray(board [64]int8, toMove Color) (*Game, error) {
+	pieces := map[int8]Piece{}
+	for piece, code := range arrayPieceCodes {
+		pieces[code] = piece.ToPiece(White)
+		pieces[-code] = piece.ToPiece(Black)
+	}
+	result := &Game{
+		Board:               NewBoard(),
+		Pieces:              NewPiecePositions(),
+		ToMove:              toMove,
+		CastleStatuses:      NewCastleStatuses(None, None),
+		EnPassantVulnerable: NoPosition,
+		Fullmove:            1,
+	}
+	for pos, code := range board {
+		if code == 0 {
+			continue
+		}
+		piece, ok := pieces[code]
+		if !ok {
+			return nil, fmt.Errorf("Unknown piece code %d on %s", code, Position(pos))
+		}
+		result.Board[pos] = piece
+		result.Pieces.AddPosition(piece, Position(pos))
+	}
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+	result.SquareControl = NewSquareControlFromBoard(result.Board)
+	result.validMoves = NewValidMovesListFromBoard(result.Board)
+	return result, nil
+}
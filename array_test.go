@@ -0,0 +1,35 @@
+package chess_engine
+
+import "testing"
+
+func Test_ToArray_and_GameFromArray(t *testing.T) {
+	fen := "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b - - 0 1"
+	unit, err := ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	board := unit.ToArray()
+	if board[A1] != 4 || board[E1] != 6 || board[F3] != 2 || board[E4] != 1 {
+		t.Errorf("Expecting positive codes for white pieces, got %v", board)
+	}
+	if board[A8] != -4 || board[D8] != -5 || board[C6] != -2 || board[E5] != -1 {
+		t.Errorf("Expecting negative codes for black pieces, got %v", board)
+	}
+	if board[E3] != 0 {
+		t.Errorf("Expecting 0 for an empty square, got %d", board[E3])
+	}
+	game, err := GameFromArray(board, Black)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.FENString() != fen {
+		t.Errorf("Expecting %s, got %s", fen, game.FENString())
+	}
+	if len(game.ValidMoves()) != len(unit.ValidMoves()) {
+		t.Errorf("Expecting the same valid moves, got %v", game.ValidMoves())
+	}
+	board[H4] = 7
+	if _, err := GameFromArray(board, White); err == nil {
+		t.Errorf("Expecting an error for an unknown piece code")
+	}
+}
@@ -0,0 +1,111 @@
+package chess_engine
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// The size of a position encoded by MarshalBinary:
+//
+//	 0-7   a bitmap of the occupied squares, a1 being the lowest bit
+//	 8-23  the Piece on every occupied square, four bits each, in square order
+//	24     the side to move (bit 0) and the White and Black CastleStatus
+//	       (bits 1-2 and 3-4)
+//	25     the file of the en passant square, or noEnPassantFile
+//	26     the halfmove clock
+//	27-28  the fullmove number
+const BinaryPositionSize = 29
+
+const noEnPassantFile = 0xf
+
+// Encodes the position in BinaryPositionSize bytes, which is a lot smaller
+// than a FEN string when storing many positions. The move history isn't
+// included.
+func (f *Game) MarshalBinary() ([]byte, error) {
+	result := make([]byte, BinaryPositionSize)
+	occupied := PositionBitmap(0)
+	pieces := 0
+	for pos, piece := range f.Board {
+		if piece == NoPiece {
+			continue
+		}
+		if pieces == 32 {
+			return nil, fmt.Errorf("Can't encode more than 32 pieces")
+		}
+		occupied = occupied.Add(Position(pos))
+		result[8+pieces/2] |= byte(piece) << (4 * uint(pieces%2))
+		pieces++
+	}
+	binary.LittleEndian.PutUint64(result[0:8], uint64(occupied))
+	if f.ToMove == Black {
+		result[24] |= 1
+	}
+	result[24] |= byte(f.CastleStatuses.White) << 1
+	result[24] |= byte(f.CastleStatuses.Black) << 3
+	result[25] = noEnPassantFile
+	if f.EnPassantVulnerable != NoPosition {
+		result[25] = byte(f.EnPassantVulnerable.GetFile() - FileA)
+	}
+	if f.HalfmoveClock < 0 || f.HalfmoveClock > 255 {
+		return nil, fmt.Errorf("Can't encode halfmove clock %d", f.HalfmoveClock)
+	}
+	result[26] = byte(f.HalfmoveClock)
+	if f.Fullmove < 0 || f.Fullmove > 65535 {
+		return nil, fmt.Errorf("Can't encode fullmove number %d", f.Fullmove)
+	}
+	binary.LittleEndian.PutUint16(result[27:29], uint16(f.Fullmove))
+	return result, nil
+}
+
+// Decodes a position encoded by MarshalBinary into @f.
+func (f *Game) UnmarshalBinary(data []byte) error {
+	if len(data) != BinaryPositionSize {
+		return fmt.Errorf("Expecting %d bytes, got %d", BinaryPositionSize, len(data))
+	}
+	result := Game{
+		Board:               NewBoard(),
+		Pieces:              NewPiecePositions(),
+		ToMove:              White,
+		EnPassantVulnerable: NoPosition,
+		HalfmoveClock:       int(data[26]),
+		Fullmove:            int(binary.LittleEndian.Uint16(data[27:29])),
+	}
+	occupied := PositionBitmap(binary.LittleEndian.Uint64(data[0:8]))
+	pieces := 0
+	for pos := Position(0); pos < 64; pos++ {
+		if !occupied.IsSet(pos) {
+			continue
+		}
+		piece := Piece(data[8+pieces/2]>>(4*uint(pieces%2))) & 0xf
+		if piece >= NoPiece {
+			return fmt.Errorf("Unknown piece %d on %s", piece, pos)
+		}
+		result.Board[pos] = piece
+		result.Pieces.AddPosition(piece, pos)
+		pieces++
+	}
+	if data[24]&1 == 1 {
+		result.ToMove = Black
+	}
+	result.CastleStatuses = NewCastleStatuses(CastleStatus(data[24]>>1&3), CastleStatus(data[24]>>3&3))
+	if file := data[25]; file != noEnPassantFile {
+		if file > 7 {
+			return fmt.Errorf("Unknown en passant file %d", file)
+		}
+		rank := Rank6
+		if result.ToMove == Black {
+			rank = Rank3
+		}
+		result.EnPassantVulnerable = PositionFromFileRank(FileA+File(file), rank)
+	}
+	if err := result.Validate(); err != nil {
+		return err
+	}
+	result.SquareControl = NewSquareControlFromBoard(result.Board)
+	result.validMoves = NewValidMovesListFromBoard(result.Board)
+	if result.EnPassantVulnerable != NoPosition {
+		result.validMoves.AddEnPassantCaptures(result.EnPassantVulnerable, result.ToMove, result.Board)
+	}
+	*f = result
+	return nil
+}
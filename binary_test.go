@@ -0,0 +1,57 @@
+package chess_engine
+
+import "testing"
+
+func Test_MarshalBinary_round_trip(t *testing.T) {
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b Kq e3 0 3",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"8/8/8/4k3/8/8/P7/7K w - - 90 312",
+	}
+	for _, fen := range fens {
+		unit, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := unit.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != BinaryPositionSize || BinaryPositionSize > 32 {
+			t.Errorf("Expecting %d bytes of at most 32, got %d", BinaryPositionSize, len(data))
+		}
+		result := &Game{}
+		if err := result.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if !result.Equal(unit) {
+			t.Errorf("Expecting %s, got %s", fen, result.FENString())
+		}
+		if len(result.ValidMoves()) != len(unit.ValidMoves()) {
+			t.Errorf("Expecting the same valid moves in %s, got %v", fen, result.ValidMoves())
+		}
+	}
+}
+
+func Test_UnmarshalBinary_errors(t *testing.T) {
+	unit := &Game{}
+	if err := unit.UnmarshalBinary([]byte{1, 2, 3}); err == nil {
+		t.Errorf("Expecting an error for too few bytes")
+	}
+	start, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := start.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[8] = 0xff
+	if err := unit.UnmarshalBinary(data); err == nil {
+		t.Errorf("Expecting an error for an unknown piece")
+	}
+}
@@ -21,7 +21,7 @@ func (b Board) IsColor(pos Position, color Color) bool {
 	return b[pos].Color() == color
 }
 func (b Board) IsOppositeColor(pos Position, color Color) bool {
-	return b[pos].Color() == color.Opposite()
+	return b[pos] != NoPiece && b[pos].Color() == color.Opposite()
 }
 
 func (b Board) IsOpposingPiece(pos Position, c Color) bool {
@@ -39,17 +39,6 @@ func (b Board) FindPieceOnLine(line []Position) Position {
 	return NoPosition
 }
 
-func (b Board) CanCastle(a SquareControl, color Color, from, to Position) bool {
-	for p := from; p <= to; p++ {
-		if !b.IsEmpty(p) {
-			return false
-		} else if a.AttacksSquare(color.Opposite(), p) {
-			return false
-		}
-	}
-	return true
-}
-
 func (b Board) ApplyMove(from, to Position) Piece {
 	capture := b[to]
 
@@ -64,6 +53,24 @@ func (b Board) Copy() Board {
 	return result
 }
 
+// Returns a copy of the board seen from the other side, so that a1 becomes a8.
+func (b Board) FlipVertical() Board {
+	result := make([]Piece, 64)
+	for pos, piece := range b {
+		result[pos^56] = piece
+	}
+	return result
+}
+
+// Returns a copy of the board where every white piece is black and vice versa.
+func (b Board) SwapColors() Board {
+	result := make([]Piece, 64)
+	for pos, piece := range b {
+		result[pos] = piece.SetColor(piece.OppositeColor())
+	}
+	return result
+}
+
 func (b Board) HasClearLineTo(from, to Position) bool {
 	vector := NewMove(from, to).Vector().Normalize()
 	for _, pos := range vector.FollowVectorUntilEdgeOfBoard(to) {
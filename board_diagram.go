@@ -0,0 +1,63 @@
+package chess_engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parses a board drawn in ASCII, which makes for more readable test
+// fixtures than FEN strings. The diagram has eight ranks, starting with the
+// eighth, using the FEN piece letters and '.' for empty squares, followed by
+// a line with the side to move ("w" or "b"). Spaces and blank lines are
+// ignored. Nobody can castle and there is no en passant square:
+//
+//	. . . . k . . .
+//	. . . . p . . .
+//	. . . . . . . .
+//	. . . . . . . .
+//	. . . . . . . .
+//	. . . . . . . .
+//	. . . . P . . .
+//	. . . . K . . .
+//	w
+func ParseBoardDiagram(diagram string) (*Game, error) {
+	lines := []string{}
+	for _, line := range strings.Split(diagram, "\n") {
+		line = strings.Replace(line, " ", "", -1)
+		line = strings.Replace(line, "\t", "", -1)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 9 {
+		return nil, fmt.Errorf("diagram: expecting 8 ranks and the side to move, got %d lines", len(lines))
+	}
+	ranks := make([]string, 8)
+	for i, line := range lines[:8] {
+		if len(line) != 8 {
+			return nil, fmt.Errorf("diagram: expecting 8 squares on rank %d, got %d", 8-i, len(line))
+		}
+		rank := ""
+		empty := 0
+		for j := 0; j < len(line); j++ {
+			if line[j] == '.' {
+				empty++
+				continue
+			}
+			if _, err := ParsePiece(line[j]); err != nil {
+				return nil, fmt.Errorf("diagram: unknown piece %q on rank %d", line[j], 8-i)
+			}
+			if empty > 0 {
+				rank += strconv.Itoa(empty)
+				empty = 0
+			}
+			rank += string(line[j])
+		}
+		if empty > 0 {
+			rank += strconv.Itoa(empty)
+		}
+		ranks[i] = rank
+	}
+	return ParseFEN(strings.Join(ranks, "/") + " " + lines[8] + " - - 0 1")
+}
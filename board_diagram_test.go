@@ -0,0 +1,40 @@
+package chess_engine
+
+import "testing"
+
+func Test_ParseBoardDiagram(t *testing.T) {
+	unit, err := ParseBoardDiagram(`
+		r . . . k . . r
+		p p p . . p p p
+		. . n . . . . .
+		. . . p P . . .
+		. . . . . . . .
+		. . . . . N . .
+		P P P . . P P P
+		R . B Q K . . R
+		b
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "r3k2r/ppp2ppp/2n5/3pP3/8/5N2/PPP2PPP/R1BQK2R b - - 0 1"
+	if unit.FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, unit.FENString())
+	}
+}
+
+func Test_ParseBoardDiagram_errors(t *testing.T) {
+	cases := []string{
+		"",
+		"........\n........\n........\n........\n........\n........\n........\n....K..k\n",
+		"........\n........\n........\n........\n........\n........\n.........\n....K..k\nw",
+		"........\n........\n........\n........\n........\n........\n.......\n....K..k\nw",
+		"........\n........\n........\n........\n........\n........\n...x....\n....K..k\nw",
+		"........\n........\n........\n........\n........\n........\n........\n....K..k\nx",
+	}
+	for _, diagram := range cases {
+		if _, err := ParseBoardDiagram(diagram); err == nil {
+			t.Errorf("Expecting an error parsing %q", diagram)
+		}
+	}
+}
@@ -11,19 +11,40 @@ import (
 func main() {
 	var engine chess_engine.Engine
 	engine = chess_engine.NewBSEngine(4)
+	xboard := false
 	for i, arg := range os.Args {
-		if arg == "--random" {
+		if arg == "--xboard" {
+			xboard = true
+		} else if arg == "--random" {
 			engine = chess_engine.NewRandomEngine()
 		} else if arg == "--naive-material" {
 			engine.AddEvaluator(chess_engine.NaiveMaterialEvaluator)
 		} else if arg == "--space" {
 			engine.AddEvaluator(chess_engine.SpaceEvaluator)
+		} else if arg == "--advanced-space" {
+			engine.AddEvaluator(chess_engine.AdvancedSpaceEvaluator)
 		} else if arg == "--tempo" {
 			engine.AddEvaluator(chess_engine.TempoEvaluator)
 		} else if arg == "--mobility" {
 			engine.AddEvaluator(chess_engine.MobilityEvaluator)
 		} else if arg == "--pawn-structure" {
 			engine.AddEvaluator(chess_engine.PawnStructureEvaluator)
+		} else if arg == "--connected-pawns" {
+			engine.AddEvaluator(chess_engine.ConnectedPawnsEvaluator)
+		} else if arg == "--rook-passer" {
+			engine.AddEvaluator(chess_engine.RookBehindPasserEvaluator)
+		} else if arg == "--hanging-pieces" {
+			engine.AddEvaluator(chess_engine.HangingPieceEvaluator)
+		} else if arg == "--king-activity" {
+			engine.AddEvaluator(chess_engine.KingActivityEvaluator)
+		} else if arg == "--full-width" {
+			engine.SetOption(chess_engine.FULLWIDTH, 1)
+		} else if arg == "--random-opening" {
+			plies, err := strconv.Atoi(os.Args[i+1])
+			if err != nil {
+				panic(err)
+			}
+			engine.SetOption(chess_engine.RANDOMOPENING, plies)
 		} else if arg == "--depth" {
 			selDepth, err := strconv.Atoi(os.Args[i+1])
 			if err != nil {
@@ -32,6 +53,12 @@ func main() {
 			engine.SetOption(chess_engine.SELDEPTH, selDepth)
 		}
 	}
+	if xboard {
+		if err := chess_engine.NewXBoard(engine).Run(os.Stdin, os.Stdout); err != nil {
+			panic(err)
+		}
+		return
+	}
 	uci := chess_engine.NewUCI("bs-engine", "Bart Spaans", engine)
 	reader := bufio.NewReader(os.Stdin)
 	uci.Start(reader)
@@ -25,18 +25,22 @@ func (c Color) String() string {
 	panic("Not a valid colour: " + strconv.Itoa(int(c)))
 }
 
+// Returns the other colour. NoColor has no opposite, so it stays NoColor.
 func (c Color) Opposite() Color {
 	if c == Black {
 		return White
-	} else {
+	} else if c == White {
 		return Black
 	}
+	return NoColor
 }
 
+// Parses the side to move indicator from a FEN string. Some tools emit it in
+// uppercase, so both "w"/"W" and "b"/"B" are accepted.
 func ParseColor(colorStr string) (Color, error) {
-	if colorStr == "w" {
+	if colorStr == "w" || colorStr == "W" {
 		return White, nil
-	} else if colorStr == "b" {
+	} else if colorStr == "b" || colorStr == "B" {
 		return Black, nil
 	}
 	return White, errors.New("pgn: invalid color")
@@ -0,0 +1,60 @@
+package chess_engine
+
+import "testing"
+
+func Test_Color_Opposite(t *testing.T) {
+	cases := map[Color]Color{
+		White:   Black,
+		Black:   White,
+		NoColor: NoColor,
+	}
+	for color, expected := range cases {
+		if color.Opposite() != expected {
+			t.Errorf("Expecting the opposite of %d to be %d, got %d", color, expected, color.Opposite())
+		}
+	}
+}
+
+func Test_Board_IsOppositeColor_on_empty_squares(t *testing.T) {
+	board := NewBoard()
+	for _, color := range []Color{White, Black, NoColor} {
+		if board.IsOppositeColor(E4, color) {
+			t.Errorf("Expecting an empty square not to be the opposite colour of %d", color)
+		}
+	}
+}
+
+func Test_NoPiece_has_no_color(t *testing.T) {
+	if NoPiece.Color() != NoColor {
+		t.Errorf("Expecting NoPiece to have no colour, got %d", NoPiece.Color())
+	}
+	if NoPiece.OppositeColor() != NoColor {
+		t.Errorf("Expecting NoPiece to have no opposite colour, got %d", NoPiece.OppositeColor())
+	}
+	for _, color := range Colors {
+		if NoPiece.IsColor(color) {
+			t.Errorf("Not expecting NoPiece to be %s", color)
+		}
+	}
+}
+
+func Test_ValidMovesList_knights_on_an_empty_square(t *testing.T) {
+	unit, err := ParseFEN("4k3/8/5n2/8/8/8/3N4/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both knights can go to e4, which is empty. Nothing happened on e4,
+	// so this shouldn't change anything.
+	valid := unit.validMoves.Copy()
+	valid.shrinkValidMovesForPiecesThatAreNowBlocked(E4, unit.Board)
+	for pos := range valid {
+		if valid[pos] != unit.validMoves[pos] {
+			t.Errorf("Expecting the valid moves for %s to stay the same, got %v", Position(pos), valid[pos].ToPositions())
+		}
+	}
+	for _, knight := range []Position{D2, F6} {
+		if !valid[knight].IsSet(E4) {
+			t.Errorf("Expecting the knight on %s to still reach e4", knight)
+		}
+	}
+}
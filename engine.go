@@ -3,9 +3,15 @@ package chess_engine
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"sort"
 	"time"
 )
 
+// How much worse than the best move a move can be for it to still be played
+// during the RandomOpening.
+const RandomOpeningMargin = Score(30)
+
 type BSEngine struct {
 	StartingPosition *Game
 	Cancel           context.CancelFunc
@@ -13,11 +19,33 @@ type BSEngine struct {
 	EvalTree         *EvalTree
 	SelDepth         int
 
+	// Search every move up to SelDepth, instead of only following forcing
+	// lines and the most promising alternatives.
+	FullWidth bool
+
+	// Stop searching and play the best move found so far after this much
+	// time. Zero means there's no time limit.
+	MoveTime time.Duration
+
+	// Play a random move out of the ones that are nearly as good as the
+	// best move for the first RandomOpening half moves of the game, so that
+	// games don't all start the same way.
+	RandomOpening int
+	Rand          *rand.Rand
+
+	// Called with the line and score of every node that's evaluated, to
+	// help trace why a move was chosen. The score is relative to the side
+	// to move at the end of the line.
+	OnNode func(line []*Move, score Score)
+
 	TotalNodes     int
 	NodesPerSecond int
 	CurrentDepth   int
 	Seen           SeenMap
 	Queue          *Queue
+
+	// Closed when the running search has finished
+	done chan struct{}
 }
 
 func NewBSEngine(depth int) *BSEngine {
@@ -37,13 +65,29 @@ func (b *BSEngine) SetPosition(fen *Game) {
 func (b *BSEngine) SetOption(opt EngineOption, val int) {
 	if opt == SELDEPTH {
 		b.SelDepth = val
+	} else if opt == FULLWIDTH {
+		b.FullWidth = val != 0
+	} else if opt == MOVETIME {
+		b.MoveTime = time.Duration(val) * time.Millisecond
+	} else if opt == RANDOMOPENING {
+		b.RandomOpening = val
+	} else if opt == RANDOMSEED {
+		b.Rand = rand.New(rand.NewSource(int64(val)))
 	}
 }
 
 func (b *BSEngine) Start(output chan string, maxNodes, maxDepth int) {
+	// The GUI doesn't have to stop a ponder search before starting a new
+	// one, but the two searches can't share the engine's state.
+	b.Stop()
 	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
 	b.Cancel = cancel
-	go b.start(ctx, output, maxNodes, maxDepth)
+	b.done = done
+	go func() {
+		defer close(done)
+		b.start(ctx, output, maxNodes, maxDepth)
+	}()
 }
 
 func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxDepth int) {
@@ -53,24 +97,53 @@ func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxD
 	b.TotalNodes = 0
 	b.Queue = NewQueue()
 
+	// There's nothing to search when the game is already over.
+	if len(b.StartingPosition.ValidMoves()) == 0 {
+		outputNoMoves(ctx, output, b.StartingPosition)
+		return
+	}
+	// Nor when there are only kings left, which is always a draw.
+	if b.StartingPosition.HasOnlyKings() {
+		sendOutput(ctx, output, "info depth 0 score cp 0")
+		sendOutput(ctx, output, fmt.Sprintf("bestmove %s", b.StartingPosition.ValidMoves()[0]))
+		return
+	}
+
 	timer := time.NewTimer(time.Second)
+	deadline := time.Time{}
+	if b.MoveTime > 0 {
+		deadline = time.Now().Add(b.MoveTime)
+	}
 	//depth := b.SelDepth + 1
 
-	b.Queue.QueueNextLine(b.StartingPosition, b.Seen, b.SelDepth, b.Evaluators)
+	if b.FullWidth {
+		b.Queue.List.PushFront(b.StartingPosition)
+	} else {
+		b.Queue.QueueNextLine(b.StartingPosition, b.Seen, b.SelDepth, b.Evaluators)
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			b.outputInfo(output, true)
+			b.outputInfo(ctx, output, true)
 			return
 		case <-timer.C:
 			b.TotalNodes += b.NodesPerSecond
 			b.NodesPerSecond = 0
 			timer = time.NewTimer(time.Second)
-			b.outputInfo(output, false)
+			b.outputInfo(ctx, output, false)
 		default:
 			if maxNodes > 0 && b.TotalNodes+b.NodesPerSecond >= maxNodes {
-				b.outputInfo(output, true)
+				// The best line is only updated at the end of a line, so
+				// make sure it reflects everything we've looked at so far.
+				b.EvalTree.UpdateBestLine()
+				b.outputInfo(ctx, output, true)
+				return
+			}
+			// Checked on every node so that we don't overshoot by much,
+			// but only once there's a move to play.
+			if !deadline.IsZero() && b.EvalTree.BestLine != nil && time.Now().After(deadline) {
+				b.outputInfo(ctx, output, true)
 				return
 			}
 			if !b.Queue.IsEmpty() {
@@ -78,20 +151,35 @@ func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxD
 				if game == nil {
 					panic("game nil")
 				}
+				b.NodesPerSecond++
 				if game.Score == nil {
 					b.Evaluators.Eval(game)
 				}
-				if *game.Score == Mate {
-					*game.Score = *game.Score - Score(float64(len(game.Line)))
+				isMate := *game.Score == Mate
+				if isMate {
+					// Prefer the shortest mate
+					*game.Score = *game.Score - Score(game.Ply()-b.StartingPosition.Ply())
+				}
+				if b.OnNode != nil {
+					b.OnNode(game.Line, *game.Score)
 				}
 				b.EvalTree.Insert(game.Line, *game.Score)
 
-				if len(game.Line) == 0 || len(game.Line) == b.SelDepth {
+				// Stop early when we've found a mate that the opponent can't
+				// get out of. Proving that walks the whole tree, so we only
+				// try when the line we just inserted ends in mate, because
+				// that's the only kind of node that can complete the proof.
+				if isMate && b.EvalTree.Score.IsMateScore() && isForcedMate(b.EvalTree, b.StartingPosition, b.StartingPosition.ToMove) {
+					b.outputInfo(ctx, output, true)
+					return
+				}
+
+				if b.FullWidth {
+					if len(game.Line) < b.SelDepth {
+						b.Queue.QueueAllLines(game)
+					}
+				} else if len(game.Line) == 0 || len(game.Line) == b.SelDepth {
 					b.EvalTree.UpdateBestLine()
-					//if b.EvalTree.Score == Mate {
-					//	b.outputInfo(output, true)
-					//	return
-					//}
 				} else if len(game.Line) < b.SelDepth {
 					// If we already found Mate at this depth we can skip
 					// this whole tree
@@ -138,6 +226,9 @@ func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxD
 						}
 					}
 				}
+			} else if b.FullWidth {
+				b.outputInfo(ctx, output, true)
+				return
 			} else {
 				// The queue is empty so there are no more moves to look at.
 				// However we can queue more moves if it turns out our current
@@ -149,14 +240,14 @@ func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxD
 					hasNext := b.Queue.QueueNextLine(b.StartingPosition, b.Seen, b.SelDepth, b.Evaluators)
 					if !hasNext {
 						//fmt.Println("we are losing")
-						b.outputInfo(output, true)
+						b.outputInfo(ctx, output, true)
 						return
 					}
 				} else {
 					//fmt.Println("We are better", *b.StartingPosition.Score, b.EvalTree.BestLine.Score)
 					//fmt.Println(Line(b.EvalTree.BestLine.GetBestLine().Line).String())
 					// Otherwise output the best move
-					b.outputInfo(output, true)
+					b.outputInfo(ctx, output, true)
 					return
 				}
 
@@ -165,25 +256,222 @@ func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxD
 	}
 }
 
-func (b *BSEngine) outputInfo(output chan string, sendBestMove bool) {
+func (b *BSEngine) outputInfo(ctx context.Context, output chan string, sendBestMove bool) {
+	if b.EvalTree.BestLine == nil {
+		// We got stopped before the first line was searched, so fall back
+		// on the move with the best static evaluation.
+		if game, score, _ := b.Evaluators.BestMove(b.StartingPosition); game != nil {
+			b.EvalTree.Insert(game.Line, score)
+		}
+	}
 	bestLine := b.EvalTree.BestLine
+	if sendBestMove && b.StartingPosition.Ply() < b.RandomOpening {
+		bestLine = b.randomOpeningMove()
+	}
 	bestResult := bestLine.GetBestLine()
 	line := Line(bestResult.Line).String()
-	output <- fmt.Sprintf("info depth %d ns %d nodes %d score cp %d pv %s",
-		len(bestResult.Line),
+	// The depth is what we're aiming for, but some lines are longer because
+	// we follow them until the position is quiet. The root of the tree
+	// doesn't count towards the selective depth.
+	sendOutput(ctx, output, fmt.Sprintf("info depth %d seldepth %d ns %d nodes %d score cp %d pv %s",
+		b.SelDepth,
+		b.EvalTree.MaxDepth()-1,
 		b.NodesPerSecond,
 		b.TotalNodes,
-		bestResult.Score.ToCentipawn(),
-		line)
+		// The score in the best line is relative to whoever is to
+		// move at the end of the line, but we want to report it from
+		// the perspective of the engine.
+		b.EvalTree.Score.ToCentipawn(),
+		line))
 	if sendBestMove {
-		output <- fmt.Sprintf("bestmove %s", bestLine.Move.String())
+		// The second move in the principal variation is the reply we expect,
+		// so the GUI can ponder on it.
+		if len(bestResult.Line) > 1 {
+			sendOutput(ctx, output, fmt.Sprintf("bestmove %s ponder %s", bestLine.Move.String(), bestResult.Line[1].String()))
+		} else {
+			sendOutput(ctx, output, fmt.Sprintf("bestmove %s", bestLine.Move.String()))
+		}
+	}
+}
+
+// Picks one of the moves that score within the RandomOpeningMargin of the
+// best move. The replies are sorted first, so that the same seed always
+// gives the same move.
+func (b *BSEngine) randomOpeningMove() *EvalTree {
+	if b.Rand == nil {
+		b.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	best := b.EvalTree.BestLine
+	candidates := []*EvalTree{}
+	for _, reply := range b.EvalTree.Replies {
+		if reply.Score >= best.Score-RandomOpeningMargin {
+			candidates = append(candidates, reply)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Move.String() < candidates[j].Move.String()
+	})
+	return candidates[b.Rand.Intn(len(candidates))]
+}
+
+// Reports a position without legal moves: either we're mated or it's
+// stalemate. The null move tells the GUI that there's nothing to play.
+func outputNoMoves(ctx context.Context, output chan string, position *Game) {
+	if position.InCheck() {
+		sendOutput(ctx, output, "info depth 0 score mate 0")
+	} else {
+		sendOutput(ctx, output, "info depth 0 score cp 0")
+	}
+	sendOutput(ctx, output, "bestmove 0000")
+}
+
+// Sends @line to @output, but gives up when the search gets stopped while
+// @output is full, so that Stop never has to wait for whoever is reading
+// the output. When there's room the line is always sent, so a stopped
+// search still reports its bestmove.
+func sendOutput(ctx context.Context, output chan string, line string) {
+	select {
+	case output <- line:
+		return
+	default:
 	}
+	select {
+	case output <- line:
+	case <-ctx.Done():
+	}
+}
+
+// Whether every reply to the moves in @tree has been searched and leads to
+// @attacker mating the opponent. @game is the position at the root of @tree.
+func isForcedMate(tree *EvalTree, game *Game, attacker Color) bool {
+	if game.ToMove == attacker {
+		if tree.BestLine == nil {
+			return false
+		}
+		return isForcedMate(tree.BestLine, game.ApplyMove(tree.BestLine.Move), attacker)
+	}
+	moves := game.ValidMoves()
+	if len(moves) == 0 {
+		return game.InCheck()
+	}
+	for _, move := range moves {
+		reply, ok := tree.Replies[move.String()]
+		if !ok || !isForcedMate(reply, game.ApplyMove(move), attacker) {
+			return false
+		}
+	}
+	return true
+}
+
+type ScoredMove struct {
+	Move  *Move
+	Score Score
+}
+
+// Searches @position to @depth and returns every valid move together with
+// its score from the perspective of the side to move, best move first.
+// Moves that weren't explored by the search get their static evaluation.
+func (b *BSEngine) AnalyzeMoves(position *Game, depth int) []ScoredMove {
+	b.SetPosition(position)
+	b.SelDepth = depth
+	output := make(chan string, 10)
+	go func() {
+		for range output {
+		}
+	}()
+	b.start(context.Background(), output, -1, -1)
+	close(output)
+
+	result := []ScoredMove{}
+	for _, game := range position.NextGames() {
+		move := game.Line[len(game.Line)-1]
+		score, _ := b.Evaluators.Eval(game)
+		if tree, ok := b.EvalTree.Replies[move.String()]; ok {
+			score = tree.Score
+		}
+		result = append(result, ScoredMove{move, score})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+	return result
+}
+
+// Tries to prove that the side to move in @position can force mate in at most
+// @maxMoves moves. Only checks and captures are considered for the attacking
+// side, but every reply is considered for the defending side. Returns the
+// mating line, with the longest defence, if a mate was found.
+func (b *BSEngine) FindMate(position *Game, maxMoves int) ([]*Move, bool) {
+	// Look for shorter mates first
+	stack := NewRepetitionStack()
+	stack.Push(position.Hash())
+	for moves := 1; moves <= maxMoves; moves++ {
+		if line, ok := findMate(position, moves, stack); ok {
+			return line, true
+		}
+	}
+	return nil, false
+}
+
+// @stack holds the hashes of the positions in the line so far, including
+// @position, and is left as it was when we return.
+func findMate(position *Game, movesLeft int, stack *RepetitionStack) ([]*Move, bool) {
+	for _, move := range position.ValidMoves() {
+		isCapture := position.IsCapture(move)
+		next := position.ApplyMove(move)
+		if !isCapture && !next.InCheck() {
+			continue
+		}
+		if next.IsMate() {
+			return []*Move{move}, true
+		}
+		if movesLeft > 1 {
+			stack.Push(next.Hash())
+			// Going back to a position we've already seen in this line
+			// can't lead to a shorter mate.
+			if stack.Count() > 1 {
+				stack.Pop()
+				continue
+			}
+			line, ok := findMateAgainstAllReplies(next, movesLeft-1, stack)
+			stack.Pop()
+			if ok {
+				return append([]*Move{move}, line...), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func findMateAgainstAllReplies(position *Game, movesLeft int, stack *RepetitionStack) ([]*Move, bool) {
+	var longest []*Move
+	for _, reply := range position.ValidMoves() {
+		next := position.ApplyMove(reply)
+		stack.Push(next.Hash())
+		line, ok := findMate(next, movesLeft, stack)
+		stack.Pop()
+		if !ok {
+			return nil, false
+		}
+		if len(line)+1 > len(longest) {
+			longest = append([]*Move{reply}, line...)
+		}
+	}
+	// No replies means stalemate, because mate was checked by the caller.
+	return longest, longest != nil
 }
 
 func (b *BSEngine) AddEvaluator(e Evaluator) {
 	b.Evaluators = append(b.Evaluators, e)
 }
 
+// Stops the search and waits for it to finish, so a new search can be
+// started right away. A stopped search still sends its bestmove if there's
+// room for it in the output, but never waits for the output to be read.
 func (b *BSEngine) Stop() {
+	if b.Cancel == nil {
+		return
+	}
 	b.Cancel()
+	<-b.done
 }
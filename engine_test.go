@@ -3,6 +3,7 @@ package chess_engine
 import (
 	"fmt"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
@@ -42,7 +43,7 @@ func getBestMove(unit *BSEngine, timeLimit time.Duration) string {
 		case output := <-outputs:
 			//fmt.Println("Received output", output)
 			if strings.HasPrefix(output, "bestmove ") {
-				bestmove = output[9:]
+				bestmove = strings.Fields(output)[1]
 				unit.Stop()
 				running = false
 			}
@@ -109,6 +110,22 @@ func runUntilMate(cases [][]string, maxSecondsPerMove time.Duration, announceRes
 	return nil
 }
 
+// Checks that the search only looked at (at most @max) checking moves from
+// the root position.
+func expectOnlyForcingMoves(t *testing.T, unit *BSEngine, fen *Game, max int) {
+	if len(unit.EvalTree.Replies) > max {
+		for move, child := range unit.EvalTree.Replies {
+			fmt.Println(move, len(child.Replies))
+		}
+		t.Fatalf("Expected only the forcing moves from the root position, got %d", len(unit.EvalTree.Replies))
+	}
+	for moveStr := range unit.EvalTree.Replies {
+		if !fen.ApplyMove(MustParseMove(moveStr)).InCheck() {
+			t.Errorf("Expected only the forcing moves from the root position, got %s", moveStr)
+		}
+	}
+}
+
 func Test_Engine_Can_Find_Mate_In_One(t *testing.T) {
 	cases := [][]string{
 		[]string{"8/8/8/qn6/kn6/1n6/1KP5/8 w - - 0 0", "1"},
@@ -165,13 +182,9 @@ func Test_Engine_Mate_In_Two_Move_Disection(t *testing.T) {
 		t.Fatalf("Expecting best move d2h6, got %v", bestmove)
 	}
 	// There are three forcing moves in this position, one of which leads to
-	// check mate. So there should only be three nodes in the root EvalTree
-	if len(unit.EvalTree.Replies) != 3 {
-		for move, child := range unit.EvalTree.Replies {
-			fmt.Println(move, len(child.Replies))
-		}
-		t.Fatalf("Expected only the forcing moves from the root position")
-	}
+	// check mate. So there should be at most three nodes in the root EvalTree;
+	// the search stops as soon as the mate is confirmed.
+	expectOnlyForcingMoves(t, unit, fen, 3)
 
 	if unit.EvalTree.MaxDepth() != 4 {
 		t.Fatalf("Expecting tree with max depth 4, got %d", unit.EvalTree.MaxDepth())
@@ -231,13 +244,8 @@ func Test_Engine_Mate_In_Two_Move_Disection_for_black(t *testing.T) {
 		t.Errorf("Expecting best move h6h2, got %v", bestmove)
 	}
 	// There are two forcing moves in this position, one of which leads to
-	// check mate. So there should only be two nodes in the root EvalTree
-	if len(unit.EvalTree.Replies) != 2 {
-		for move, child := range unit.EvalTree.Replies {
-			fmt.Println(move, len(child.Replies))
-		}
-		t.Fatalf("Expected only the forcing moves from the root position, got %d", len(unit.EvalTree.Replies))
-	}
+	// check mate. So there should be at most two nodes in the root EvalTree
+	expectOnlyForcingMoves(t, unit, fen, 2)
 }
 func Test_Engine_Mate_In_Two_Move_Disection_for_black_2(t *testing.T) {
 	pos := "7r/p3ppk1/3p4/2p1P1Kp/2Pb4/3P1QPq/PP5P/R6R b - - 0 1"
@@ -257,18 +265,8 @@ func Test_Engine_Mate_In_Two_Move_Disection_for_black_2(t *testing.T) {
 		t.Errorf("Expecting best move h6h2, got %v", bestmove)
 	}
 	// There are six forcing moves in this position, one of which leads to
-	// check mate. So there should only be six nodes in the root EvalTree
-	if len(unit.EvalTree.Replies) != 6 {
-		fmt.Println(fen.Board)
-		for move, child := range unit.EvalTree.Replies {
-			fmt.Println(move, len(child.Replies))
-		}
-		fmt.Println()
-		for move, child := range unit.EvalTree.Replies["d4e3"].Replies["f3e3"].Replies {
-			fmt.Println(move, len(child.Replies), child.Score)
-		}
-		t.Fatalf("Expected only the forcing moves from the root position, got %d", len(unit.EvalTree.Replies))
-	}
+	// check mate. So there should be at most six nodes in the root EvalTree
+	expectOnlyForcingMoves(t, unit, fen, 6)
 	if unit.EvalTree.MaxDepth() != 4 {
 		t.Fatalf("Expecting tree with max depth 4")
 	}
@@ -499,6 +497,212 @@ func Test_Engine_should_take_free_material(t *testing.T) {
 	}
 }
 
+func Test_Engine_plays_black_from_a_fen(t *testing.T) {
+	// Black can take a free knight on g5
+	fen, err := ParseFEN("rnbqkbnr/ppp2ppp/8/3pp1N1/8/8/PPPPPPPP/RNBQKB1R b KQkq - 1 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, depth := range []int{3, 4} {
+		unit := NewBSEngine(depth)
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.AddEvaluator(SpaceEvaluator)
+		unit.SetPosition(fen)
+		outputs := make(chan string, 1000)
+		unit.Start(outputs, 0, 0)
+		score := 0
+		bestmove := ""
+		for bestmove == "" {
+			parts := strings.Fields(<-outputs)
+			if parts[0] == "info" {
+				score, _ = strconv.Atoi(parts[11])
+			} else if parts[0] == "bestmove" {
+				bestmove = parts[1]
+			}
+		}
+		unit.Stop()
+		move := MustParseMove(bestmove)
+		if fen.Board[move.From].Color() != Black {
+			t.Errorf("Expecting a move for black, got %s", bestmove)
+		}
+		if bestmove != "d8g5" {
+			t.Errorf("Expecting d8g5 at depth %d, got %s", depth, bestmove)
+		}
+		if score <= 0 {
+			t.Errorf("Expecting a positive score for black at depth %d, got %d", depth, score)
+		}
+	}
+}
+
+func Test_Engine_FindMate(t *testing.T) {
+	cases := []struct {
+		pos       string
+		mateIn    int
+		firstMove string
+	}{
+		{"r1bq2r1/b4pk1/p1pp1p2/1p2pP2/1P2P1PB/3P4/1PPQ2P1/R3K2R w - - 0 1", 2, "d2h6"},
+		{"1r4k1/3b2pp/1b1pP2r/pp1P4/4q3/8/PP4RP/2Q2R1K b - - 0 1", 2, "h6h2"},
+		{"6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1", 1, "d1d8"},
+	}
+	unit := NewBSEngine(4)
+	for _, c := range cases {
+		fen, err := ParseFEN(c.pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line, ok := unit.FindMate(fen, 3)
+		if !ok {
+			t.Errorf("Expecting a mate in %d in %s", c.mateIn, c.pos)
+			continue
+		}
+		if len(line) != c.mateIn*2-1 || line[0].String() != c.firstMove {
+			t.Errorf("Expecting a mate in %d starting with %s in %s, got %v", c.mateIn, c.firstMove, c.pos, line)
+		}
+		for _, move := range line {
+			fen = fen.ApplyMove(move)
+		}
+		if !fen.IsMate() {
+			t.Errorf("Expecting line %v to end in mate in %s", line, c.pos)
+		}
+	}
+	fen, err := ParseFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line, ok := unit.FindMate(fen, 2); ok {
+		t.Errorf("Expecting no mate, got %v", line)
+	}
+}
+
+func Test_Engine_reports_score_in_centipawns(t *testing.T) {
+	// White is up a rook, which is worth 550 centipawns
+	fen, err := ParseFEN("3k4/pp6/8/8/8/8/PP6/R2K4 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(2)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	outputs := make(chan string, 1000)
+	unit.Start(outputs, 0, 0)
+	defer unit.Stop()
+	info := ""
+	for {
+		output := <-outputs
+		if strings.HasPrefix(output, "bestmove ") {
+			break
+		}
+		info = output
+	}
+	if !strings.Contains(info, " score cp 550 ") {
+		t.Errorf("Expecting score cp 550, got '%s'", info)
+	}
+}
+
+func Test_Engine_reports_depth_and_seldepth(t *testing.T) {
+	fen, err := ParseFEN("rnbqkb1r/pppppppp/8/8/3PP1n1/8/PPP2PPP/RNBQKBNR w KQkq - 1 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(3)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.AddEvaluator(SpaceEvaluator)
+	unit.SetPosition(fen)
+	outputs := make(chan string, 1000)
+	unit.Start(outputs, 0, 0)
+	defer unit.Stop()
+	info := ""
+	for {
+		output := <-outputs
+		if strings.HasPrefix(output, "bestmove ") {
+			break
+		}
+		info = output
+	}
+	parts := strings.Fields(info)
+	if parts[1] != "depth" || parts[3] != "seldepth" {
+		t.Fatalf("Expecting depth and seldepth in '%s'", info)
+	}
+	depth, _ := strconv.Atoi(parts[2])
+	seldepth, _ := strconv.Atoi(parts[4])
+	pvLength := len(strings.Fields(info[strings.Index(info, " pv ")+4:]))
+	if depth != 3 {
+		t.Errorf("Expecting depth 3, got %d", depth)
+	}
+	if seldepth < pvLength || seldepth < 1 {
+		t.Errorf("Expecting the seldepth to be at least the length of the principal variation, got %d in '%s'", seldepth, info)
+	}
+}
+
+func Test_Engine_stops_when_mate_is_found(t *testing.T) {
+	nodes := func(pos string) int {
+		fen, err := ParseFEN(pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit := NewBSEngine(4)
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.AddEvaluator(SpaceEvaluator)
+		unit.SetPosition(fen)
+		if getBestMove(unit, 5*time.Second) == "" {
+			t.Fatal("Did not get a best move in time", pos)
+		}
+		return unit.TotalNodes + unit.NodesPerSecond
+	}
+	mateInOne := nodes("6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1")
+	quiet := nodes("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if mateInOne*10 > quiet {
+		t.Errorf("Expecting the mate in one to take far fewer nodes than the quiet position, got %d and %d", mateInOne, quiet)
+	}
+}
+
+func Test_Engine_FullWidth_finds_quiet_moves(t *testing.T) {
+	// The selective search settles on d2d4 here, because it never looks
+	// at the quiet bishop development.
+	fen, err := ParseFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(3)
+	unit.SetOption(FULLWIDTH, 1)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.AddEvaluator(SpaceEvaluator)
+	unit.SetPosition(fen)
+	bestmove := getBestMove(unit, 10*time.Second)
+	if bestmove != "f1b5" {
+		t.Errorf("Expecting f1b5, got %s", bestmove)
+	}
+}
+
+func Test_Engine_Shouldnt_Stalemate_Lone_King(t *testing.T) {
+	// Each position has at least one tempting move that leaves
+	// the lone king without legal moves, but not in check.
+	cases := []string{
+		"8/8/8/8/8/5K2/4Q3/7k w - - 0 1",
+		"7k/5Q2/8/6K1/8/8/8/8 w - - 0 1",
+		"k7/8/8/2QK4/8/8/8/8 w - - 0 1",
+		"k7/2R5/1K6/8/8/8/8/8 w - - 0 1",
+		"K7/8/1k6/8/8/8/8/2q5 b - - 0 1",
+	}
+	for _, pos := range cases {
+		for depth := 1; depth <= 4; depth++ {
+			fen, err := ParseFEN(pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unit := NewBSEngine(depth)
+			unit.AddEvaluator(NaiveMaterialEvaluator)
+			unit.AddEvaluator(SpaceEvaluator)
+			unit.SetPosition(fen)
+			bestmove := getBestMove(unit, 3*time.Second)
+			next := fen.ApplyMove(MustParseMove(bestmove))
+			if !next.InCheck() && len(next.ValidMoves()) == 0 {
+				t.Errorf("Expecting %s not to stalemate at depth %d in %s", bestmove, depth, pos)
+			}
+		}
+	}
+}
+
 func Test_Engine_Should_find_better_move_if_forcing_lines_dont_work_out(t *testing.T) {
 	// Here the queen can take with check, but she can be captured straight
 	// away, so this line should be avoided.
@@ -569,6 +773,29 @@ func Test_Engine_Should_find_better_move_if_forcing_lines_dont_work_out_black(t
 	}
 }
 
+func Benchmark_Search_depth_3(b *testing.B) {
+	fen, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		unit := NewBSEngine(3)
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.AddEvaluator(SpaceEvaluator)
+		unit.SetPosition(fen)
+		outputs := make(chan string, 1000)
+		unit.Start(outputs, -1, -1)
+		for output := range outputs {
+			if strings.HasPrefix(output, "bestmove ") {
+				break
+			}
+		}
+		unit.Stop()
+	}
+}
+
 func Benchmark_MateInTwo(t *testing.B) {
 	cases := [][]string{
 		[]string{"r1bq2r1/b4pk1/p1pp1p2/1p2pP2/1P2P1PB/3P4/1PPQ2P1/R3K2R w - - 0 0", "3"},
@@ -593,3 +820,441 @@ func Benchmark_MateInTwo(t *testing.B) {
 		}
 	}
 }
+
+func Test_Engine_AnalyzeMoves(t *testing.T) {
+	pos := "1r4k1/3b2pp/1b1pP2r/pp1P4/4q3/8/PP4RP/2Q2R1K b - - 0 1"
+	fen, err := ParseFEN(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(3)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.AddEvaluator(SpaceEvaluator)
+	unit.SetPosition(fen)
+	bestmove := getBestMove(unit, 3*time.Second)
+
+	fen, err = ParseFEN(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit = NewBSEngine(3)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.AddEvaluator(SpaceEvaluator)
+	moves := unit.AnalyzeMoves(fen, 3)
+	if len(moves) != len(fen.ValidMoves()) {
+		t.Errorf("Expecting a score for all %d moves, got %d", len(fen.ValidMoves()), len(moves))
+	}
+	if moves[0].Move.String() != bestmove {
+		t.Errorf("Expecting best move %s to be analyzed first, got %s", bestmove, moves[0].Move)
+	}
+	for i := 1; i < len(moves); i++ {
+		if moves[i].Score > moves[i-1].Score {
+			t.Errorf("Expecting moves sorted best-first, got %v after %v", moves[i], moves[i-1])
+		}
+	}
+}
+
+func Test_Engine_bestmove_includes_ponder_move(t *testing.T) {
+	fen, err := ParseFEN("r1bq2r1/b4pk1/p1pp1p2/1p2pP2/1P2P1PB/3P4/1PPQ2P1/R3K2R w - - 0 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(3)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.AddEvaluator(SpaceEvaluator)
+	unit.SetPosition(fen)
+	outputs := make(chan string, 1000)
+	unit.Start(outputs, 0, 0)
+	defer unit.Stop()
+	timer := time.NewTimer(3 * time.Second)
+	for {
+		select {
+		case <-timer.C:
+			t.Fatal("Did not get a best move in time")
+		case output := <-outputs:
+			if !strings.HasPrefix(output, "bestmove ") {
+				continue
+			}
+			pv := unit.EvalTree.BestLine.GetBestLine().Line
+			parts := strings.Fields(output)
+			if len(parts) != 4 || parts[2] != "ponder" {
+				t.Fatalf("Expecting a ponder move in '%s'", output)
+			}
+			if parts[1] != pv[0].String() || parts[3] != pv[1].String() {
+				t.Errorf("Expecting bestmove %s ponder %s, got '%s'", pv[0], pv[1], output)
+			}
+			return
+		}
+	}
+}
+
+func Test_Engine_without_legal_moves_plays_the_null_move(t *testing.T) {
+	cases := map[string]string{
+		// Checkmate
+		"R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1": "info depth 0 score mate 0",
+		// Stalemate
+		"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1": "info depth 0 score cp 0",
+	}
+	for fenStr, expectedInfo := range cases {
+		fen, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, unit := range []Engine{NewBSEngine(4), NewRandomEngine()} {
+			unit.SetPosition(fen)
+			outputs := make(chan string, 10)
+			unit.Start(outputs, 0, 0)
+			info, bestmove := "", ""
+			timer := time.NewTimer(3 * time.Second)
+			for bestmove == "" {
+				select {
+				case output := <-outputs:
+					if strings.HasPrefix(output, "info ") {
+						info = output
+					} else if strings.HasPrefix(output, "bestmove ") {
+						bestmove = output
+					}
+				case <-timer.C:
+					t.Fatalf("Expecting a bestmove in %s", fenStr)
+				}
+			}
+			unit.Stop()
+			if bestmove != "bestmove 0000" {
+				t.Errorf("Expecting 'bestmove 0000' in %s, got '%s'", fenStr, bestmove)
+			}
+			if info != expectedInfo {
+				t.Errorf("Expecting '%s' in %s, got '%s'", expectedInfo, fenStr, info)
+			}
+		}
+	}
+}
+
+func Test_Engine_stops_at_the_movetime(t *testing.T) {
+	fen, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(10)
+	unit.SetPosition(fen)
+	unit.SetOption(MOVETIME, 300)
+	outputs := make(chan string, 1000)
+	started := time.Now()
+	unit.Start(outputs, 0, 0)
+	defer unit.Stop()
+	timer := time.NewTimer(3 * time.Second)
+	bestmove := ""
+	for bestmove == "" {
+		select {
+		case output := <-outputs:
+			if strings.HasPrefix(output, "bestmove ") {
+				bestmove = strings.Fields(output)[1]
+			}
+		case <-timer.C:
+			t.Fatalf("Expecting a bestmove before the time was up")
+		}
+	}
+	// The upper bound leaves plenty of room for a loaded machine or -race,
+	// but would still catch a search that ignores the movetime.
+	elapsed := time.Since(started)
+	if elapsed < unit.MoveTime || elapsed > unit.MoveTime+500*time.Millisecond {
+		t.Errorf("Expecting the search to take about %s, took %s", unit.MoveTime, elapsed)
+	}
+	move, err := ParseMove(bestmove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fen.IsLegalMove(move) {
+		t.Errorf("Expecting a legal move, got %s", bestmove)
+	}
+}
+
+func Test_Engine_makes_progress_before_the_fifty_move_rule(t *testing.T) {
+	fen, err := ParseFEN("8/8/8/4k3/8/8/P7/7K w - - 90 80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(1)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	bestmove := getBestMove(unit, 3*time.Second)
+	move := MustParseMove(bestmove)
+	if fen.Board[move.From] != WhitePawn {
+		t.Errorf("Expecting a pawn move instead of shuffling the king, got %s", bestmove)
+	}
+}
+
+func Test_Engine_RandomOpening(t *testing.T) {
+	bestMove := func(seed int, randomOpening int, fen string) string {
+		position, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit := NewBSEngine(1)
+		unit.AddEvaluator(SpaceEvaluator)
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetOption(FULLWIDTH, 1)
+		unit.SetOption(RANDOMOPENING, randomOpening)
+		unit.SetOption(RANDOMSEED, seed)
+		unit.SetPosition(position)
+		return getBestMove(unit, time.Second)
+	}
+	start := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	best := bestMove(1, 0, start)
+	played := map[string]bool{}
+	for seed := 1; seed <= 10; seed++ {
+		move := bestMove(seed, 2, start)
+		if move != bestMove(seed, 2, start) {
+			t.Errorf("Expecting the same move for seed %d", seed)
+		}
+		played[move] = true
+	}
+	if len(played) < 2 {
+		t.Errorf("Expecting different opening moves, got %v", played)
+	}
+	for seed := 1; seed <= 10; seed++ {
+		if move := bestMove(seed, 0, start); move != best {
+			t.Errorf("Expecting the best move %s without a random opening, got %s", best, move)
+		}
+	}
+	// The queen is hanging, so there's only one good move
+	later := "rnb1kbnr/pppp1ppp/8/4p1q1/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 3"
+	for seed := 1; seed <= 10; seed++ {
+		if move := bestMove(seed, 4, later); move != "f3g5" {
+			t.Errorf("Expecting f3g5 after the random opening, got %s", move)
+		}
+	}
+}
+
+func Test_Engine_OnNode(t *testing.T) {
+	fen, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(1)
+	unit.AddEvaluator(SpaceEvaluator)
+	unit.SetOption(FULLWIDTH, 1)
+	unit.SetPosition(fen)
+	rootMoves := map[string]bool{}
+	unit.OnNode = func(line []*Move, score Score) {
+		if len(line) == 1 {
+			rootMoves[line[0].String()] = true
+		}
+	}
+	if getBestMove(unit, time.Second) == "" {
+		t.Fatal("Expecting a best move")
+	}
+	if len(rootMoves) != 20 {
+		t.Errorf("Expecting OnNode to be called with all 20 root moves, got %v", rootMoves)
+	}
+	for _, move := range fen.ValidMoves() {
+		if !rootMoves[move.String()] {
+			t.Errorf("Expecting OnNode to be called with %s", move)
+		}
+	}
+}
+
+func Test_Engine_ponder_miss_restarts_the_search(t *testing.T) {
+	fen, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := runtime.NumGoroutine()
+	unit := NewBSEngine(10)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	outputs := make(chan string, 1000)
+
+	// Ponder on a reply that doesn't get played
+	ponder := fen.ApplyMove(MustParseMove("e2a6"))
+	unit.SetPosition(ponder.AsSearchRoot())
+	unit.Start(outputs, -1, -1)
+	time.Sleep(50 * time.Millisecond)
+	unit.Stop()
+	stopped := false
+	for len(outputs) > 0 {
+		if strings.HasPrefix(<-outputs, "bestmove ") {
+			stopped = true
+		}
+	}
+	if !stopped {
+		t.Errorf("Expecting a bestmove when the ponder search is stopped")
+	}
+
+	actual := fen.ApplyMove(MustParseMove("d2c1"))
+	unit.SetPosition(actual.AsSearchRoot())
+	unit.SetOption(MOVETIME, 100)
+	bestmove := getBestMove(unit, time.Second)
+	move, err := ParseMove(bestmove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.IsLegalMove(move) {
+		t.Errorf("Expecting a legal move in the new position, got %s", bestmove)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expecting the searches to finish, got %d goroutines instead of %d", after, before)
+	}
+}
+
+func Test_Engine_node_limit_reports_the_best_line_so_far(t *testing.T) {
+	fen, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, maxNodes := range []int{50, 200, 1000} {
+		unit := NewBSEngine(4)
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetPosition(fen)
+		outputs := make(chan string, 1000)
+		unit.Start(outputs, maxNodes, -1)
+		bestmove := ""
+		for output := range outputs {
+			if strings.HasPrefix(output, "bestmove ") {
+				bestmove = strings.Fields(output)[1]
+				break
+			}
+		}
+		unit.Stop()
+		bestScore := LowestScore
+		for _, reply := range unit.EvalTree.Replies {
+			if reply.Score > bestScore {
+				bestScore = reply.Score
+			}
+		}
+		reply, ok := unit.EvalTree.Replies[bestmove]
+		if !ok {
+			t.Errorf("Expecting bestmove %s to be in the tree after %d nodes", bestmove, maxNodes)
+		} else if reply.Score != bestScore {
+			t.Errorf("Expecting bestmove %s to have the best score %d after %d nodes, got %d", bestmove, bestScore, maxNodes, reply.Score)
+		}
+	}
+}
+
+func Test_Engine_stopped_before_the_first_line_still_plays_a_move(t *testing.T) {
+	fen, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R b KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stopping right away sometimes beats the search to its first node,
+	// so we try a few times.
+	for i := 0; i < 100; i++ {
+		unit := NewBSEngine(4)
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetPosition(fen)
+		outputs := make(chan string, 100)
+		unit.Start(outputs, -1, -1)
+		unit.Stop()
+		close(outputs)
+		bestmove := ""
+		for output := range outputs {
+			if strings.HasPrefix(output, "bestmove ") {
+				bestmove = strings.Fields(output)[1]
+			}
+		}
+		move, err := ParseMove(bestmove)
+		if err != nil {
+			t.Fatalf("Expecting a best move, got %q", bestmove)
+		}
+		if !fen.IsLegalMove(move) {
+			t.Fatalf("Expecting a legal best move, got %s", bestmove)
+		}
+	}
+}
+
+func Test_Engine_bare_kings_are_a_draw_without_searching(t *testing.T) {
+	fen, err := ParseFEN("8/8/3k4/8/8/4K3/8/8 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(4)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	outputs := make(chan string, 10)
+	unit.Start(outputs, 0, 0)
+	unit.Stop()
+	close(outputs)
+	info, bestmove := "", ""
+	for output := range outputs {
+		if strings.HasPrefix(output, "info ") {
+			info = output
+		} else if strings.HasPrefix(output, "bestmove ") {
+			bestmove = strings.Fields(output)[1]
+		}
+	}
+	if info != "info depth 0 score cp 0" {
+		t.Errorf("Expecting a draw score, got '%s'", info)
+	}
+	if move, err := ParseMove(bestmove); err != nil || !fen.IsLegalMove(move) {
+		t.Errorf("Expecting a legal best move, got '%s'", bestmove)
+	}
+	if nodes := unit.TotalNodes + unit.NodesPerSecond; nodes != 0 {
+		t.Errorf("Expecting no nodes to be searched, got %d", nodes)
+	}
+}
+
+func Test_Engine_Stop_doesnt_wait_for_the_output_to_be_read(t *testing.T) {
+	fen, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(10)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	// Nobody reads the output, so it fills up with info lines
+	outputs := make(chan string, 1)
+	stopped := make(chan struct{})
+	go func() {
+		unit.Start(outputs, -1, -1)
+		time.Sleep(1500 * time.Millisecond)
+		unit.Start(outputs, -1, -1)
+		unit.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expecting Stop to return while the output is full")
+	}
+}
+
+func Test_Engine_doesnt_reuse_a_winning_score_at_the_fifty_move_rule(t *testing.T) {
+	// Both positions have the same Zobrist hash, but on the second one every
+	// move that doesn't mate draws by the fifty move rule.
+	winning, err := ParseFEN("k7/8/8/8/8/8/8/4K1Q1 w - - 0 80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drawn, err := ParseFEN("k7/8/8/8/8/8/8/4K1Q1 w - - 99 80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(4)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	score := func(fen *Game) string {
+		unit.SetPosition(fen)
+		outputs := make(chan string, 1000)
+		unit.Start(outputs, -1, -1)
+		defer unit.Stop()
+		info := ""
+		for output := range outputs {
+			if strings.HasPrefix(output, "info ") {
+				info = output
+			} else if strings.HasPrefix(output, "bestmove ") {
+				break
+			}
+		}
+		fields := strings.Fields(info)
+		for i, field := range fields {
+			if field == "score" && i+2 < len(fields) {
+				return fields[i+1] + " " + fields[i+2]
+			}
+		}
+		return ""
+	}
+	if s := score(winning); s == "cp 0" {
+		t.Errorf("Expecting white to be winning, got %s", s)
+	}
+	if s := score(drawn); s != "cp 0" {
+		t.Errorf("Expecting a draw by the fifty move rule, got %s", s)
+	}
+}
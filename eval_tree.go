@@ -1,5 +1,11 @@
 package chess_engine
 
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
 type EvalResult struct {
 	Score
 	Line []*Move
@@ -168,3 +174,53 @@ func (t *EvalTree) MaxDepth() int {
 	}
 	return depth + 1
 }
+
+// Writes the tree in Graphviz DOT format, so it can be rendered with e.g.
+// `dot -Tpng`. Every node is labeled with its move and score, and the edges
+// to the best lines are drawn in bold. Only the first @maxDepth plies are
+// written, because the trees get big quickly.
+func (t *EvalTree) WriteDOT(w io.Writer, maxDepth int) error {
+	if _, err := fmt.Fprintln(w, "digraph EvalTree {"); err != nil {
+		return err
+	}
+	id := 0
+	if err := t.writeDOTNode(w, &id, maxDepth); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+func (t *EvalTree) writeDOTNode(w io.Writer, id *int, depth int) error {
+	nodeID := *id
+	*id++
+	label := "root"
+	if t.Move != nil {
+		label = t.Move.String()
+	}
+	if _, err := fmt.Fprintf(w, "  n%d [label=\"%s\\n%d\"];\n", nodeID, label, t.Score); err != nil {
+		return err
+	}
+	if depth == 0 {
+		return nil
+	}
+	moves := []string{}
+	for move := range t.Replies {
+		moves = append(moves, move)
+	}
+	sort.Strings(moves)
+	for _, move := range moves {
+		reply := t.Replies[move]
+		style := ""
+		if reply == t.BestLine {
+			style = " [style=bold]"
+		}
+		if _, err := fmt.Fprintf(w, "  n%d -> n%d%s;\n", nodeID, *id, style); err != nil {
+			return err
+		}
+		if err := reply.writeDOTNode(w, id, depth-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
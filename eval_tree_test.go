@@ -1,6 +1,8 @@
 package chess_engine
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -107,3 +109,32 @@ func Test_EvalTree_prune(t *testing.T) {
 	}
 	unit.Insert([]*Move{m1, m3, m2}, 1.0)
 }
+
+func Test_EvalTree_WriteDOT(t *testing.T) {
+	unit := NewEvalTree(nil)
+	unit.Insert([]*Move{NewMove(A2, A3)}, 100)
+	unit.Insert([]*Move{NewMove(E2, E4)}, 150)
+	unit.Insert([]*Move{NewMove(E2, E4), NewMove(E7, E5)}, 120)
+	buf := bytes.NewBuffer(nil)
+	if err := unit.WriteDOT(buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	expected := `digraph EvalTree {
+  n0 [label="root\n100"];
+  n0 -> n1 [style=bold];
+  n1 [label="a2a3\n100"];
+  n0 -> n2;
+  n2 [label="e2e4\n-120"];
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Expecting:\n%s\ngot:\n%s", expected, buf.String())
+	}
+	buf.Reset()
+	if err := unit.WriteDOT(buf, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `n3 [label="e7e5\n120"];`) {
+		t.Errorf("Expecting the reply to e2e4 at depth 2, got:\n%s", buf.String())
+	}
+}
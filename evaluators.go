@@ -5,27 +5,22 @@ import (
 	"math/rand"
 )
 
+// Evaluators return a Score in centipawns, positive if the position is better
+// for White. The score is reported to UCI as is, so a pawn should be worth
+// about 100.
 type Evaluator func(fen *Game, phase int) Score
 
 type Evaluators []Evaluator
 
 func NaiveMaterialEvaluator(f *Game, phase int) Score {
 	score := 0
-	materialScore := map[NormalizedPiece]int{
-		Pawn:   100,
-		Knight: 325,
-		Bishop: 325,
-		King:   400,
-		Rook:   550,
-		Queen:  1100,
-	}
 	for pieceIx, positions := range f.Pieces[White] {
 		piece := NormalizedPiece(pieceIx)
-		score += positions.Count() * materialScore[piece]
+		score += positions.Count() * piece.Value()
 	}
 	for pieceIx, positions := range f.Pieces[Black] {
 		piece := NormalizedPiece(pieceIx)
-		score += -1 * positions.Count() * materialScore[piece]
+		score += -1 * positions.Count() * piece.Value()
 	}
 	return Score(score)
 }
@@ -118,6 +113,165 @@ func PawnStructureEvaluator(f *Game, phase int) Score {
 
 }
 
+// Rewards pawns that are defended by another pawn (chains) or that stand
+// next to another pawn on the same rank (phalanxes). Pawn structure matters
+// more as pieces come off the board, so the bonus grows towards the endgame.
+func ConnectedPawnsEvaluator(f *Game, phase int) Score {
+	DefendedPawnBonus := 20
+	PhalanxBonus := 10
+	score := 0
+	for _, color := range Colors {
+		bonus := 0
+		pawn := Pawn.ToPiece(color)
+		for _, pawnPos := range f.Pieces[color][Pawn].ToPositions() {
+			for _, defender := range pawnPos.GetPawnAttacks(color.Opposite()) {
+				if f.Board[defender] == pawn {
+					bonus += DefendedPawnBonus
+				}
+			}
+			if pawnPos.GetFile() != FileH && f.Board[pawnPos+1] == pawn {
+				bonus += PhalanxBonus
+			}
+		}
+		if color == White {
+			score += bonus
+		} else {
+			score -= bonus
+		}
+	}
+	return Score(score * (256 - phase) / 256)
+}
+
+// Rewards rooks that stand behind their own passed pawns, where they support
+// the pawn's advance, and penalises enemy rooks behind them, which hold the
+// pawn back from the rear. Matters more towards the endgame.
+func RookBehindPasserEvaluator(f *Game, phase int) Score {
+	RookBehindPasserBonus := 40
+	score := 0
+	for _, color := range Colors {
+		bonus := 0
+		behind := Position(-8)
+		if color == Black {
+			behind = 8
+		}
+		for _, pawnPos := range f.Pieces[color][Pawn].ToPositions() {
+			if !f.IsPassedPawn(pawnPos) {
+				continue
+			}
+			for p := pawnPos + behind; p >= 0 && p < 64; p += behind {
+				if f.Board[p] == Rook.ToPiece(color) {
+					bonus += RookBehindPasserBonus
+				} else if f.Board[p] == Rook.ToPiece(color.Opposite()) {
+					bonus -= RookBehindPasserBonus
+				}
+				if !f.Board.IsEmpty(p) {
+					break
+				}
+			}
+		}
+		if color == White {
+			score += bonus
+		} else {
+			score -= bonus
+		}
+	}
+	return Score(score * (256 - phase) / 256)
+}
+
+// Penalises the side that just moved for leaving pieces en prise, because
+// the side to move can take one of them. Only the most valuable threatened
+// piece counts, and only at half its value, because ThreatenedPieces doesn't
+// look at the exchanges that follow.
+func HangingPieceEvaluator(f *Game, phase int) Score {
+	color := f.ToMove.Opposite()
+	penalty := 0
+	for _, pos := range f.ThreatenedPieces(color) {
+		if value := f.Board[pos].Value() / 2; value > penalty {
+			penalty = value
+		}
+	}
+	if color == White {
+		return Score(-penalty)
+	}
+	return Score(penalty)
+}
+
+// Recognises endgames that are drawn even though one side is ahead in
+// material, so Eval can score them as a draw:
+//
+//   - King, bishop and rook pawns against a king that is in front of the
+//     pawns, when the bishop can't control the promotion square.
+//   - King and two knights against a king, which can't force mate.
+func isFortress(f *Game) bool {
+	for _, color := range Colors {
+		if cantWin(f, color) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether @color can't win, even though it's ahead in material.
+func cantWin(f *Game, color Color) bool {
+	if f.Pieces.CountPositionsForColor(color.Opposite()) != 1 {
+		return false
+	}
+	pieces := f.Pieces[color]
+	bishops, knights, pawns := pieces[Bishop].Count(), pieces[Knight].Count(), pieces[Pawn].Count()
+	if pieces[Queen].Count()+pieces[Rook].Count() > 0 {
+		return false
+	}
+	if knights == 2 && bishops == 0 && pawns == 0 {
+		return true
+	}
+	if bishops != 1 || knights != 0 || pawns == 0 {
+		return false
+	}
+	promotionRank := Rank8
+	if color == Black {
+		promotionRank = Rank1
+	}
+	file := pieces[Pawn].ToPositions()[0].GetFile()
+	for _, pawn := range pieces[Pawn].ToPositions() {
+		if pawn.GetFile() != file || (file != FileA && file != FileH) {
+			return false
+		}
+	}
+	promotionSquare := PositionFromFileRank(file, promotionRank)
+	bishop := pieces[Bishop].ToPositions()[0]
+	if bishop.IsLightSquare() == promotionSquare.IsLightSquare() {
+		return false
+	}
+	defendingKing := f.Pieces.GetKingPos(color.Opposite())
+	return defendingKing.ChebyshevDistance(promotionSquare) <= 1
+}
+
+// Rewards kings that are close to the centre, where they can support their
+// own pawns and stop the enemy's. The king should stay safe in the opening,
+// so this only kicks in as the pieces come off.
+func KingActivityEvaluator(f *Game, phase int) Score {
+	KingCentralisationBonus := 10
+	score := 0
+	for _, color := range Colors {
+		if f.Pieces[color][King].IsEmpty() {
+			continue
+		}
+		king := f.Pieces.GetKingPos(color)
+		distance := 3
+		for _, centre := range []Position{D4, D5, E4, E5} {
+			if d := king.ChebyshevDistance(centre); d < distance {
+				distance = d
+			}
+		}
+		if color == White {
+			score += KingCentralisationBonus * (3 - distance)
+		} else {
+			score -= KingCentralisationBonus * (3 - distance)
+		}
+	}
+	return Score(score * (256 - phase) / 256)
+}
+
 func MobilityEvaluator(f *Game, phase int) Score {
 	score := len(f.GetValidMovesForColor(White)) - len(f.GetValidMovesForColor(Black))
 	return Score(5 * score)
@@ -134,6 +288,49 @@ func SpaceEvaluator(f *Game, phase int) Score {
 	return Score(score)
 }
 
+// A more standard measure of space than SpaceEvaluator: counts the squares
+// behind each side's pawns that the enemy pawns don't attack, i.e. the room
+// the pieces have to manoeuvre. Squares in the opponent's half count double.
+// Space matters less as the pieces come off, so this is scaled by the phase.
+func AdvancedSpaceEvaluator(f *Game, phase int) Score {
+	SafeSquareBonus := 3
+	score := 0
+	for _, color := range Colors {
+		enemyPawnAttacks := PositionBitmap(0)
+		for _, pawnPos := range f.Pieces[color.Opposite()][Pawn].ToPositions() {
+			for _, pos := range pawnPos.GetPawnAttacks(color.Opposite()) {
+				enemyPawnAttacks = enemyPawnAttacks.Add(pos)
+			}
+		}
+		forward := Position(8)
+		if color == Black {
+			forward = -8
+		}
+		space := 0
+		for _, pawnPos := range f.Pieces[color][Pawn].ToPositions() {
+			for pos := pawnPos - forward; pos >= 0 && pos < 64; pos -= forward {
+				if f.Board[pos] == Pawn.ToPiece(color) {
+					break
+				}
+				if enemyPawnAttacks.IsSet(pos) {
+					continue
+				}
+				if inOpponentsHalf := pos >= 32; inOpponentsHalf == (color == White) {
+					space += 2 * SafeSquareBonus
+				} else {
+					space += SafeSquareBonus
+				}
+			}
+		}
+		if color == White {
+			score += space
+		} else {
+			score -= space
+		}
+	}
+	return Score(score * phase / 256)
+}
+
 func TempoEvaluator(f *Game, phase int) Score {
 	score := 0
 	MinorPieceMoveBonus := 30 // "A pawn is worth about 3 tempi"
@@ -170,7 +367,7 @@ func TempoEvaluator(f *Game, phase int) Score {
 			if f.CastleStatuses.White == None {
 				if pos == G1 && f.Board[H1] != WhiteRook {
 					score += CastleBonus // We're castled kingside
-				} else if pos == C1 && f.Board[A1] != WhiteRook && f.Board[A2] != WhiteRook {
+				} else if pos == C1 && f.Board[A1] != WhiteRook && f.Board[B1] != WhiteRook {
 					score += CastleBonus // We're castled queenside
 				} else if pos != E1 {
 					score -= EarlyKingMovePenalty
@@ -209,20 +406,21 @@ func (e Evaluators) Eval(position *Game) (Score, bool) {
 		return *position.Score, false
 	}
 	score := Score(0)
-	if position.IsDraw() {
-		score = Draw
-	} else if position.IsMate() {
-		score = Mate
+	// Mate takes precedence over the fifty move rule
+	if position.IsMate() {
 		if position.ToMove == White {
 			score = OpponentMate // because we're going to *-1 below
 		} else {
 			score = Mate
 		}
+	} else if position.IsDraw() || isFortress(position) {
+		score = Draw
 	} else {
 		phase := position.Phase()
 		for _, eval := range e {
 			score += eval(position, phase)
 		}
+		score = discountForTheFiftyMoveRule(position, score)
 	}
 	if position.ToMove == White {
 		score = score * -1
@@ -231,6 +429,18 @@ func (e Evaluators) Eval(position *Game) (Score, bool) {
 	return score, true
 }
 
+// Discounts the score as the fifty move rule draws near, so that the winning
+// side makes progress by pushing pawns or trading pieces before the game is
+// drawn. Halfway through the fifty moves it starts discounting, up to half
+// the score right before the draw.
+func discountForTheFiftyMoveRule(f *Game, score Score) Score {
+	progress := f.HalfmovesSinceProgress()
+	if progress <= 50 {
+		return score
+	}
+	return score * Score(150-progress) / 100
+}
+
 func (e Evaluators) BestMove(position *Game) (*Game, Score, int) {
 	bestScore := LowestScore
 	var bestGame *Game
@@ -291,7 +501,7 @@ func (e Evaluators) GetAlternativeMove(position *Game, seen map[string]bool) (*G
 	nodes := 0
 	var nextBestGame *Game
 	for _, game := range position.NextGames() {
-		if _, ok := seen[game.FENString()]; !ok {
+		if _, ok := seen[game.SeenKey()]; !ok {
 			score, new := e.Eval(game)
 			if new {
 				nodes++
@@ -44,6 +44,35 @@ func Test_Eval_mate_black(t *testing.T) {
 	}
 }
 
+func Test_Eval_stalemate_is_a_draw(t *testing.T) {
+	cases := []string{
+		"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1",
+		"8/8/8/8/8/6k1/5q2/7K w - - 0 1",
+	}
+	unit := Evaluators([]Evaluator{NaiveMaterialEvaluator})
+	for _, fen := range cases {
+		position, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		score, _ := unit.Eval(position)
+		if score != Draw {
+			t.Errorf("Expecting a draw in %s, got %v", fen, score)
+		}
+	}
+}
+
+func Test_Eval_mate_on_the_fiftieth_move(t *testing.T) {
+	position, err := ParseFEN("7k/6Q1/6K1/8/8/8/8/8 b - - 100 80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	score, _ := Evaluators([]Evaluator{}).Eval(position)
+	if score != Mate {
+		t.Errorf("Expecting mate, got %v", score)
+	}
+}
+
 func init() {
 	pos := "8/8/8/qn6/kn6/1n6/1KP5/8 w - - 0 0"
 	position, err := ParseFEN(pos)
@@ -161,14 +190,14 @@ func Test_Eval_AlternativeMove_space_evaluator(t *testing.T) {
 	}
 
 	fenMap := map[string]bool{}
-	fenMap[fen] = true
+	fenMap[position.SeenKey()] = true
 
 	game, _ := unit.GetAlternativeMove(position, fenMap)
 	if game.Line[0].String() != "e2e4" {
 		t.Errorf("Expecting e2e4 as opening move for space evaluator, got %s", game.Line)
 	}
 
-	fenMap[game.FENString()] = true
+	fenMap[game.SeenKey()] = true
 
 	game, _ = unit.GetAlternativeMove(position, fenMap)
 	if game.Line[0].String() != "d2d4" {
@@ -185,14 +214,14 @@ func Test_Eval_AlternativeMove_space_evaluator_black(t *testing.T) {
 	}
 
 	fenMap := map[string]bool{}
-	fenMap[fen] = true
+	fenMap[position.SeenKey()] = true
 
 	game, _ := unit.GetAlternativeMove(position, fenMap)
 	if game.Line[0].String() != "e7e5" {
 		t.Errorf("Expecting e7e5 as opening move for space evaluator, got %s", game.Line)
 	}
 
-	fenMap[game.FENString()] = true
+	fenMap[game.SeenKey()] = true
 
 	game, _ = unit.GetAlternativeMove(position, fenMap)
 	if game.Line[0].String() != "d7d5" {
@@ -232,6 +261,137 @@ func Test_TempoEvaluator(t *testing.T) {
 	}
 }
 
+func Test_ConnectedPawnsEvaluator(t *testing.T) {
+	score := func(fen string) Score {
+		position, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ConnectedPawnsEvaluator(position, position.Phase())
+	}
+	disconnected := score("4k3/8/8/8/2P2P2/8/8/4K3 w - - 0 1")
+	phalanx := score("4k3/8/8/8/3PP3/8/8/4K3 w - - 0 1")
+	chain := score("4k3/8/8/8/3P4/4P3/8/4K3 w - - 0 1")
+	if disconnected != 0 {
+		t.Errorf("Expecting no bonus for disconnected pawns, got %d", disconnected)
+	}
+	if phalanx <= disconnected || chain <= disconnected {
+		t.Errorf("Expecting connected pawns to score higher, got %d and %d", phalanx, chain)
+	}
+	if score("4k3/8/4p3/3p4/8/8/8/4K3 w - - 0 1") != -chain {
+		t.Errorf("Expecting the same bonus for black")
+	}
+	if score("4k3/8/8/8/8/8/P6P/4K3 w - - 0 1") != 0 {
+		t.Errorf("Expecting the a and h file pawns not to be connected")
+	}
+	opening := score("rnbqkbnr/8/8/8/3P4/4P3/8/RNBQKBNR w KQkq - 0 1")
+	if opening >= chain {
+		t.Errorf("Expecting the bonus to be smaller in the opening, got %d and %d", opening, chain)
+	}
+}
+
+func Test_RookBehindPasserEvaluator(t *testing.T) {
+	score := func(fen string) Score {
+		position, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return RookBehindPasserEvaluator(position, position.Phase())
+	}
+	behind := score("4k3/8/8/3P4/8/8/8/3RK3 w - - 0 1")
+	elsewhere := score("4k3/8/8/3P4/8/8/8/4K2R w - - 0 1")
+	if behind <= elsewhere {
+		t.Errorf("Expecting a rook behind the passed pawn to score higher, got %d and %d", behind, elsewhere)
+	}
+	if elsewhere != 0 {
+		t.Errorf("Expecting no bonus for a rook elsewhere, got %d", elsewhere)
+	}
+	if enemy := score("4k3/8/8/3P4/8/8/3r4/4K3 w - - 0 1"); enemy >= 0 {
+		t.Errorf("Expecting an enemy rook behind the passed pawn to be penalised, got %d", enemy)
+	}
+	if blocked := score("4k3/8/8/3P4/8/8/3B4/3RK3 w - - 0 1"); blocked != 0 {
+		t.Errorf("Expecting no bonus when a piece is in between, got %d", blocked)
+	}
+	if notPassed := score("4k3/2p5/8/3P4/8/8/8/3RK3 w - - 0 1"); notPassed != 0 {
+		t.Errorf("Expecting no bonus when the pawn isn't passed, got %d", notPassed)
+	}
+	if black := score("3rk3/8/8/8/3p4/8/8/4K3 w - - 0 1"); black != -behind {
+		t.Errorf("Expecting the same bonus for black, got %d", black)
+	}
+}
+
+func Test_HangingPieceEvaluator(t *testing.T) {
+	score := func(fen string) Score {
+		position, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return HangingPieceEvaluator(position, position.Phase())
+	}
+	// White just left the queen en prise to the knight
+	hanging := score("4k3/8/5n2/8/4Q3/8/8/4K3 b - - 0 1")
+	if hanging != -550 {
+		t.Errorf("Expecting the hanging queen to cost white 550, got %d", hanging)
+	}
+	if safe := score("4k3/8/5n2/8/8/8/8/4QK2 b - - 0 1"); safe != 0 {
+		t.Errorf("Expecting no penalty when nothing is hanging, got %d", safe)
+	}
+	// It's white's move, so the queen can get away
+	if toMove := score("4k3/8/5n2/8/4Q3/8/8/4K3 w - - 0 1"); toMove != 0 {
+		t.Errorf("Expecting no penalty for the side to move, got %d", toMove)
+	}
+	if black := score("4k3/8/8/4q3/8/5N2/8/4K3 w - - 0 1"); black != 550 {
+		t.Errorf("Expecting the hanging queen to cost black 550, got %d", black)
+	}
+}
+
+func Test_AdvancedSpaceEvaluator(t *testing.T) {
+	score := func(fen string) Score {
+		position, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return AdvancedSpaceEvaluator(position, position.Phase())
+	}
+	if start := score("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"); start != 0 {
+		t.Errorf("Expecting no advantage in the starting position, got %d", start)
+	}
+	center := score("rnbqkbnr/ppp2ppp/3pp3/8/2PPP3/8/PP3PPP/RNBQKBNR b KQkq - 0 4")
+	cramped := score("rnbqkbnr/ppp2ppp/3pp3/8/8/3PP3/PPP2PPP/RNBQKBNR b KQkq - 0 4")
+	if center <= 0 || center <= cramped {
+		t.Errorf("Expecting a big pawn center to give white more space, got %d and %d", center, cramped)
+	}
+	if cramped != 0 {
+		t.Errorf("Expecting a symmetrical position to be equal, got %d", cramped)
+	}
+	endgame := score("4k3/ppp2ppp/3pp3/8/2PPP3/8/PP3PPP/4K3 b - - 0 4")
+	if endgame >= center {
+		t.Errorf("Expecting space to matter less in the endgame, got %d and %d", endgame, center)
+	}
+}
+
+func Test_KingActivityEvaluator(t *testing.T) {
+	central, err := ParseFEN("4k3/4p3/8/8/4K3/8/4P3/8 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backRank, err := ParseFEN("4k3/4p3/8/8/8/8/4P3/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	centralScore := KingActivityEvaluator(central, central.Phase())
+	backRankScore := KingActivityEvaluator(backRank, backRank.Phase())
+	if centralScore <= backRankScore {
+		t.Errorf("Expecting a centralised king to score higher in the endgame, got %d and %d", centralScore, backRankScore)
+	}
+	if backRankScore != 0 {
+		t.Errorf("Expecting kings on their back ranks to be equal, got %d", backRankScore)
+	}
+	if opening := KingActivityEvaluator(central, 256); opening != 0 {
+		t.Errorf("Expecting the king's activity not to matter in the opening, got %d", opening)
+	}
+}
+
 func Benchmark_Eval(t *testing.B) {
 
 	fen := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
@@ -246,3 +406,140 @@ func Benchmark_Eval(t *testing.B) {
 		game.Score = nil
 	}
 }
+
+func Test_Evaluators_discount_for_the_fifty_move_rule(t *testing.T) {
+	evaluators := Evaluators([]Evaluator{NaiveMaterialEvaluator, SpaceEvaluator})
+	score := func(fen string) Score {
+		position, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		score, _ := evaluators.Eval(position)
+		return score
+	}
+	undiscounted := score("8/8/8/4k3/8/8/3PK3/8 b - - 0 80")
+	if s := score("8/8/8/4k3/8/8/3PK3/8 b - - 50 80"); s != undiscounted {
+		t.Errorf("Expecting no discount before the halfway point, got %d and %d", s, undiscounted)
+	}
+	near := score("8/8/8/4k3/8/8/3PK3/8 b - - 98 80")
+	if near != undiscounted*52/100 {
+		t.Errorf("Expecting the score to be discounted close to the draw, got %d and %d", near, undiscounted)
+	}
+	if s := score("8/3pk3/8/8/4K3/8/8/8 w - - 98 80"); s != near {
+		t.Errorf("Expecting the same discount for black, got %d and %d", s, near)
+	}
+}
+
+func Test_Evaluators_score_fortresses_as_a_draw(t *testing.T) {
+	evaluators := Evaluators([]Evaluator{NaiveMaterialEvaluator, SpaceEvaluator})
+	cases := map[string]bool{
+		// The bishop doesn't control a8, and the king is in the corner
+		"k7/8/8/8/P7/8/8/2B1K3 w - - 0 1":   true,
+		"8/1k6/8/8/P7/P7/8/2B1K3 w - - 0 1": true,
+		// The right bishop
+		"k7/8/8/8/P7/8/8/3BK3 w - - 0 1": false,
+		// The king is too far away
+		"8/8/8/2k5/P7/8/8/2B1K3 w - - 0 1": false,
+		// Not a rook pawn
+		"k7/8/8/8/1P6/8/8/2B1K3 w - - 0 1": false,
+		// Black has something to defend with
+		"k7/p7/8/8/P7/8/8/2B1K3 w - - 0 1": false,
+		// Two knights can't force mate
+		"8/8/8/3k4/8/8/8/1N2K1N1 w - - 0 1":  true,
+		"8/8/8/3k4/8/8/P7/1N2K1N1 w - - 0 1": false,
+	}
+	for fenStr, drawn := range cases {
+		fen, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, position := range []*Game{fen, fen.Mirror()} {
+			score, _ := evaluators.Eval(position)
+			if drawn && score != 0 {
+				t.Errorf("Expecting %s to be scored as a draw, got %d", position.FENString(), score)
+			} else if !drawn && score == 0 {
+				t.Errorf("Expecting %s not to be scored as a draw", position.FENString())
+			}
+		}
+	}
+}
+
+func Test_Evaluators_count_multiple_queens(t *testing.T) {
+	position, err := ParseFEN("4k3/8/8/8/8/8/8/QQ2K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position.Pieces.Count() != 4 {
+		t.Errorf("Expecting 4 pieces, got %d", position.Pieces.Count())
+	}
+	if len(position.Pieces.Positions(White, Queen)) != 2 {
+		t.Errorf("Expecting two white queens, got %v", position.Pieces.Positions(White, Queen))
+	}
+	if position.Pieces.GetKingPos(White) != E1 || position.Pieces.GetKingPos(Black) != E8 {
+		t.Errorf("Expecting the kings on e1 and e8")
+	}
+	if score := NaiveMaterialEvaluator(position, position.Phase()); score != 2200 {
+		t.Errorf("Expecting both queens to be counted, got %d", score)
+	}
+	// One queen is still on d1, the other one isn't
+	position, err = ParseFEN("4k3/8/8/8/8/8/8/Q2QK3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oneQueenMoved, err := ParseFEN("4k3/8/8/8/8/8/8/3QK3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if TempoEvaluator(position, 256)-TempoEvaluator(oneQueenMoved, 256) != -100 {
+		t.Errorf("Expecting the queen that isn't on d1 to be penalised")
+	}
+	// Promoting every pawn to a queen can't take us past the opening
+	position, err = ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/QQQQQQQQ/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position.Phase() != 256 {
+		t.Errorf("Expecting the phase to be at most 256, got %d", position.Phase())
+	}
+}
+
+func Test_Evaluator_symmetry(t *testing.T) {
+	evaluators := map[string]Evaluator{
+		"NaiveMaterialEvaluator":    NaiveMaterialEvaluator,
+		"PawnStructureEvaluator":    PawnStructureEvaluator,
+		"ConnectedPawnsEvaluator":   ConnectedPawnsEvaluator,
+		"RookBehindPasserEvaluator": RookBehindPasserEvaluator,
+		"KingActivityEvaluator":     KingActivityEvaluator,
+		"HangingPieceEvaluator":     HangingPieceEvaluator,
+		"MobilityEvaluator":         MobilityEvaluator,
+		"SpaceEvaluator":            SpaceEvaluator,
+		"AdvancedSpaceEvaluator":    AdvancedSpaceEvaluator,
+		"TempoEvaluator":            TempoEvaluator,
+	}
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"2kr3r/pp3ppp/8/3P4/8/8/PP4PP/R1K4R w - - 60 30",
+		"8/8/8/3k4/8/8/P7/P1B1K3 w - - 0 1",
+		"1r4k1/8/8/7P/P7/8/3R4/6K1 b - - 0 40",
+		"4k3/8/8/8/8/8/8/1RK5 w - - 0 1",
+	}
+	for _, fen := range fens {
+		position, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mirror := position.Mirror()
+		for name, eval := range evaluators {
+			score := eval(position, position.Phase())
+			mirrored := eval(mirror, mirror.Phase())
+			if score != -mirrored {
+				t.Errorf("Expecting %s to be symmetrical in %s, got %d and %d", name, fen, score, mirrored)
+			}
+		}
+	}
+}
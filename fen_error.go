@@ -0,0 +1,32 @@
+package chess_engine
+
+import "fmt"
+
+// The part of a FEN string that couldn't be parsed.
+type FENField string
+
+const (
+	FENBoard     FENField = "board"
+	FENColor     FENField = "color"
+	FENCastle    FENField = "castle"
+	FENEnPassant FENField = "enpassant"
+	FENClock     FENField = "clock"
+)
+
+// Returned by ParseFEN, so that callers can point out which part of the FEN
+// string is wrong.
+type FENError struct {
+	Field   FENField
+	Message string
+}
+
+func NewFENError(field FENField, message string) *FENError {
+	return &FENError{
+		Field:   field,
+		Message: message,
+	}
+}
+
+func (e *FENError) Error() string {
+	return fmt.Sprintf("fen: %s: %s", e.Field, e.Message)
+}
@@ -2,7 +2,9 @@ package chess_engine
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type Game struct {
@@ -31,40 +33,50 @@ type Game struct {
 	// The line we're currently pondering on
 	Line []*Move
 
+	// The piece captured by the last move in the Line, if any
+	lastCaptured Piece
+
 	// The parent Game, if any
 	Parent *Game
 
 	// Valid moves cache
 	valid *[]*Move
 
+	// Check status cache, filled in by ApplyMove
+	inCheck *bool
+
 	// Evaluation cache
 	Score *Score
 
 	nextGames []*Game
+
+	// Zobrist hash cache, zero when it hasn't been computed yet
+	hash uint64
 }
 
 func ParseFEN(fenstr string) (*Game, error) {
 	fen := Game{}
-	forStr := ""
-	colorStr := ""
-	castleStr := ""
-	enPassant := ""
-	_, err := fmt.Sscanf(fenstr, "%s %s %s %s %d %d",
-		&forStr,
-		&colorStr,
-		&castleStr,
-		&enPassant,
-		&fen.HalfmoveClock,
-		&fen.Fullmove,
-	)
-	if err != nil {
-		return nil, err
+	fields := strings.Fields(fenstr)
+	fieldNames := []FENField{FENBoard, FENColor, FENCastle, FENEnPassant, FENClock, FENClock}
+	if len(fields) < len(fieldNames) {
+		return nil, NewFENError(fieldNames[len(fields)], "missing")
+	}
+	forStr, colorStr, castleStr, enPassant := fields[0], fields[1], fields[2], fields[3]
+	var err error
+	if fen.HalfmoveClock, err = strconv.Atoi(fields[4]); err != nil {
+		return nil, NewFENError(FENClock, "invalid halfmove clock "+fields[4])
+	}
+	if fen.Fullmove, err = strconv.Atoi(fields[5]); err != nil {
+		return nil, NewFENError(FENClock, "invalid fullmove number "+fields[5])
 	}
 	color, err := ParseColor(colorStr)
 	if err != nil {
-		return nil, err
+		return nil, NewFENError(FENColor, "invalid colour "+colorStr)
 	}
 	fen.ToMove = color
+	if strings.Trim(castleStr, "KQkq") != "" && castleStr != "-" {
+		return nil, NewFENError(FENCastle, "invalid castling rights "+castleStr)
+	}
 	fen.CastleStatuses = NewCastleStatusesFromString(castleStr)
 
 	if enPassant == "-" {
@@ -72,7 +84,7 @@ func ParseFEN(fenstr string) (*Game, error) {
 	} else {
 		fen.EnPassantVulnerable, err = ParsePosition(enPassant)
 		if err != nil {
-			return nil, err
+			return nil, NewFENError(FENEnPassant, err.Error())
 		}
 	}
 	fen.Board = NewBoard()
@@ -82,32 +94,168 @@ func ParseFEN(fenstr string) (*Game, error) {
 	for i := 0; i < len(forStr); i++ {
 		// if we're at the end of the row
 		if forStr[i] == '/' {
+			if y == 0 {
+				return nil, NewFENError(FENBoard, "too many ranks")
+			}
 			x = 0
 			y--
 		} else if forStr[i] >= '1' && forStr[i] <= '8' {
 			// if we have blank squares
 			j, err := strconv.Atoi(string(forStr[i]))
 			if err != nil {
-				return nil, err
+				return nil, NewFENError(FENBoard, err.Error())
 			}
 			x += j
 		} else {
 			// if we have a piece
+			if x > 7 || y < 0 {
+				return nil, NewFENError(FENBoard, "too many squares")
+			}
 			pos := y*8 + x
 			piece, err := ParsePiece(forStr[i])
 			if err != nil {
-				return nil, err
+				return nil, NewFENError(FENBoard, err.Error())
 			}
 			fen.Board[pos] = piece
 			fen.Pieces.AddPosition(piece, Position(pos))
 			x++
 		}
 	}
+	if err := fen.Validate(); err != nil {
+		return nil, err
+	}
 	fen.SquareControl = NewSquareControlFromBoard(fen.Board)
 	fen.validMoves = NewValidMovesListFromBoard(fen.Board)
+	if fen.EnPassantVulnerable != NoPosition {
+		fen.validMoves.AddEnPassantCaptures(fen.EnPassantVulnerable, fen.ToMove, fen.Board)
+	}
 	return &fen, nil
 }
 
+// Checks that the position could occur in a game.
+func (f *Game) Validate() error {
+	for _, whiteKing := range f.Pieces.Positions(White, King) {
+		for _, blackKing := range f.Pieces.Positions(Black, King) {
+			if whiteKing.ChebyshevDistance(blackKing) < 2 {
+				return NewFENError(FENBoard, fmt.Sprintf("kings can't be next to each other on %s and %s", whiteKing, blackKing))
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the colour swapped version of this Game, with the board flipped so
+// that the pawns keep moving in the right direction. Useful for checking that
+// evaluations are symmetrical.
+func (f *Game) Mirror() *Game {
+	result := &Game{
+		Board:               f.Board.FlipVertical().SwapColors(),
+		Pieces:              NewPiecePositions(),
+		ToMove:              f.ToMove.Opposite(),
+		CastleStatuses:      NewCastleStatuses(f.CastleStatuses.Black, f.CastleStatuses.White),
+		EnPassantVulnerable: NoPosition,
+		HalfmoveClock:       f.HalfmoveClock,
+		Fullmove:            f.Fullmove,
+	}
+	for pos, piece := range result.Board {
+		if piece != NoPiece {
+			result.Pieces.AddPosition(piece, Position(pos))
+		}
+	}
+	result.SquareControl = NewSquareControlFromBoard(result.Board)
+	result.validMoves = NewValidMovesListFromBoard(result.Board)
+	if f.EnPassantVulnerable != NoPosition {
+		result.EnPassantVulnerable = f.EnPassantVulnerable ^ 56
+		result.validMoves.AddEnPassantCaptures(result.EnPassantVulnerable, result.ToMove, result.Board)
+	}
+	return result
+}
+
+type PlacedPiece struct {
+	Piece Piece
+	Pos   Position
+}
+
+// Returns all the pieces on the board ordered by position, starting at a1.
+func (f *Game) PieceList() []PlacedPiece {
+	result := []PlacedPiece{}
+	for pos, piece := range f.Board {
+		if piece != NoPiece {
+			result = append(result, PlacedPiece{piece, Position(pos)})
+		}
+	}
+	return result
+}
+
+// Whether a piece of colour @by could recapture on @sq. Unlike a valid move,
+// this is also true when @sq is occupied by one of @by's own pieces, because
+// the SquareControl keeps track of the squares that pieces are looking at,
+// including the ones they're blocked by. Lines through the enemy king don't
+// count, so this agrees with ThreatenedPieces.
+func (f *Game) IsSquareDefended(sq Position, by Color) bool {
+	return len(f.attackersOf(by, sq)) > 0
+}
+
+// Returns the positions of @color's pieces that the opponent can win, because
+// they're attacked and either not defended or attacked by a cheaper piece.
+// Kings are never included. This doesn't look at the exchanges that follow,
+// so it's only an estimate.
+func (f *Game) ThreatenedPieces(color Color) []Position {
+	result := []Position{}
+	for pos, piece := range f.Board {
+		if piece == NoPiece || piece.Color() != color || piece.ToNormalizedPiece() == King {
+			continue
+		}
+		attackers := f.attackersOf(color.Opposite(), Position(pos))
+		if len(attackers) == 0 {
+			continue
+		}
+		if len(f.attackersOf(color, Position(pos))) == 0 {
+			result = append(result, Position(pos))
+			continue
+		}
+		// The king can't take a defended piece
+		for _, attacker := range attackers {
+			attackingPiece := f.Board[attacker].ToNormalizedPiece()
+			if attackingPiece != King && attackingPiece.Value() < piece.Value() {
+				result = append(result, Position(pos))
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Returns the positions of @color's pieces attacking @pos. The SquareControl
+// follows lines through the enemy king, so those attacks are skipped.
+func (f *Game) attackersOf(color Color, pos Position) []Position {
+	result := []Position{}
+	for _, from := range f.SquareControl.Get(color, pos).ToPositions() {
+		if f.Board[from].IsSlider() && !f.Board.HasClearLineTo(from, pos) {
+			continue
+		}
+		result = append(result, from)
+	}
+	return result
+}
+
+// Returns the squares @piece would attack if it was placed on @pos, taking
+// the pieces that are currently on the board into account as blockers.
+// Whatever is on @pos itself is ignored. The squares of the blocking pieces
+// are included, whatever their colour, because they're defended or attacked.
+func (f *Game) AttacksFrom(piece Piece, pos Position) []Position {
+	result := []Position{}
+	for _, line := range pos.GetAttackVectors(piece) {
+		for _, toPos := range line {
+			result = append(result, toPos)
+			if !f.Board.IsEmpty(toPos) {
+				break
+			}
+		}
+	}
+	return result
+}
+
 // Returns new Games for every valid move from the current Game
 func (f *Game) NextGames() []*Game {
 	if f.nextGames != nil {
@@ -124,17 +272,139 @@ func (f *Game) NextGames() []*Game {
 
 func (f *Game) IsDraw() bool {
 	// Fifty move rule
-	if f.HalfmoveClock >= 100 {
+	if f.HalfmovesSinceProgress() >= 100 {
 		return true
 	}
-	checks := f.GetChecks()
-	if len(checks) > 0 {
+	// Threefold repetition
+	if f.RepetitionCountInLine() >= 3 {
+		return true
+	}
+	if f.HasInsufficientMaterial() {
+		return true
+	}
+	return f.IsStalemate()
+}
+
+// Whether neither side has enough material left to mate: king against king,
+// king and a minor piece against king, or king and bishop against king and
+// bishop with the bishops on the same colour.
+func (f *Game) HasInsufficientMaterial() bool {
+	for _, color := range Colors {
+		pieces := f.Pieces[color]
+		if !pieces[Pawn].IsEmpty() || !pieces[Rook].IsEmpty() || !pieces[Queen].IsEmpty() {
+			return false
+		}
+		if pieces[Knight].Count()+pieces[Bishop].Count() > 1 {
+			return false
+		}
+	}
+	minors := 0
+	for _, color := range Colors {
+		minors += f.Pieces[color][Knight].Count() + f.Pieces[color][Bishop].Count()
+	}
+	if minors <= 1 {
+		return true
+	}
+	whiteBishops, blackBishops := f.Pieces[White][Bishop], f.Pieces[Black][Bishop]
+	if whiteBishops.Count() != 1 || blackBishops.Count() != 1 {
 		return false
 	}
-	// TODO: draw by repetition
-	// TODO: draw by insufficient material
-	// Stalemate
-	return len(f.ValidMoves()) == 0
+	return whiteBishops.ToPositions()[0].IsLightSquare() == blackBishops.ToPositions()[0].IsLightSquare()
+}
+
+// Returns all the squares that @color attacks. See
+// SquareControl.AttackedSquares.
+func (f *Game) AttackedSquares(color Color) PositionBitmap {
+	return f.SquareControl.AttackedSquares(color)
+}
+
+// Whether both sides only have their king left.
+func (f *Game) HasOnlyKings() bool {
+	for _, color := range Colors {
+		for piece, positions := range f.Pieces[color] {
+			if NormalizedPiece(piece) != King && !positions.IsEmpty() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Returns the number of half moves since the last capture or pawn move. The
+// game is drawn by the fifty move rule when this reaches 100.
+func (f *Game) HalfmovesSinceProgress() int {
+	return f.HalfmoveClock
+}
+
+// Positions with fewer half moves than this since the last capture or pawn
+// move are far enough from the fifty move rule that it can't change the score
+// of the lines that are searched from them, so SeenKey ignores their clock.
+const SeenKeyMaxHalfmoveClock = 30
+
+// Returns the FEN string, but without the parts that don't change how the
+// position is searched, so that transpositions are treated as the same search
+// node: the en passant square if none of the pawns can actually capture on
+// it, and the halfmove clock while it's below SeenKeyMaxHalfmoveClock. Closer
+// to the fifty move rule the clock is kept, because the same position can be
+// a win with one clock and a draw with another.
+func (f *Game) SeenKey() string {
+	ignoreEnPassant := f.EnPassantVulnerable != NoPosition && !f.canCaptureEnPassant()
+	ignoreClock := f.HalfmovesSinceProgress() < SeenKeyMaxHalfmoveClock
+	if !ignoreEnPassant && !ignoreClock {
+		return f.FENString()
+	}
+	fields := strings.Fields(f.FENString())
+	if ignoreEnPassant {
+		fields[3] = "-"
+	}
+	if ignoreClock {
+		fields[4] = "-"
+	}
+	return strings.Join(fields, " ")
+}
+
+func (f *Game) canCaptureEnPassant() bool {
+	for _, pos := range f.EnPassantVulnerable.GetPawnAttacks(f.ToMove.Opposite()) {
+		if f.Board[pos] == Pawn.ToPiece(f.ToMove) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns how many times the current position has occurred, including this
+// occurrence, by following the Parent games. This covers both the line that
+// is being searched and the game leading up to it. Positions can't repeat
+// across captures and pawn moves so we stop looking there. The positions are
+// compared by their Zobrist hash, so we don't need to build FEN strings.
+func (f *Game) RepetitionCountInLine() int {
+	key := f.Hash()
+	count := 1
+	game := f
+	for game.Parent != nil && game.HalfmoveClock > 0 {
+		game = game.Parent
+		if game.Hash() == key {
+			count++
+		}
+	}
+	return count
+}
+
+// Whether the pawn on @pos can't be stopped by enemy pawns on its own file
+// or the adjacent files on its way to promotion.
+func (f *Game) IsPassedPawn(pos Position) bool {
+	pawn := f.Board[pos]
+	if pawn.ToNormalizedPiece() != Pawn {
+		return false
+	}
+	files := FileMask(pos.GetFile()) | AdjacentFilesMask(pos.GetFile())
+	ahead := PositionBitmap(0)
+	for rank := Rank1; rank <= Rank8; rank++ {
+		if pawn.Color() == White && rank > pos.GetRank() || pawn.Color() == Black && rank < pos.GetRank() {
+			ahead |= RankMask(rank)
+		}
+	}
+	return f.Pieces[pawn.OppositeColor()][Pawn]&files&ahead == 0
 }
 
 func (f *Game) GetChecks() []*Move {
@@ -142,20 +412,37 @@ func (f *Game) GetChecks() []*Move {
 }
 
 func (f *Game) InCheck() bool {
-	return len(f.GetChecks()) > 0
+	if f.inCheck == nil {
+		inCheck := len(f.GetChecks()) > 0
+		f.inCheck = &inCheck
+	}
+	return *f.inCheck
+}
+
+// Whether the last move mated the opponent. This is the same as IsCheckmate,
+// but it's cheap for positions that aren't check, because ApplyMove already
+// worked out whether the move gave check.
+func (f *Game) DeliveredMate() bool {
+	return f.LastMove() != nil && f.IsCheckmate()
 }
 
 func (f *Game) IsFinished() bool {
 	return f.IsMate() || f.IsDraw()
 }
 
+// Whether the side to move is in check and has no valid moves left.
+func (f *Game) IsCheckmate() bool {
+	return f.InCheck() && len(f.ValidMoves()) == 0
+}
+
+// Whether the side to move isn't in check, but has no valid moves left.
+func (f *Game) IsStalemate() bool {
+	return !f.InCheck() && len(f.ValidMoves()) == 0
+}
+
+// IsMate is an alias for IsCheckmate.
 func (f *Game) IsMate() bool {
-	checks := f.GetChecks()
-	if len(checks) > 0 {
-		moves := f.validMovesInCheck(checks)
-		return len(moves) == 0
-	}
-	return false
+	return f.IsCheckmate()
 }
 
 func (f *Game) validMovesInCheck(checks []*Move) []*Move {
@@ -231,6 +518,14 @@ func (f *Game) validMovesInCheck(checks []*Move) []*Move {
 				result = append(result, move)
 			}
 		}
+		// 4. remove the attacking pawn by capturing it en passant
+		if attackingPiece == Pawn && f.EnPassantCaptureSquare() == check.From {
+			for _, pos := range f.EnPassantVulnerable.GetPawnAttacks(f.ToMove.Opposite()) {
+				if f.Board[pos] == Pawn.ToPiece(f.ToMove) {
+					result = append(result, NewMove(pos, f.EnPassantVulnerable))
+				}
+			}
+		}
 	}
 
 	return f.FilterPinnedPieces(result)
@@ -260,15 +555,206 @@ func (f *Game) FilterPinnedPieces(result []*Move) []*Move {
 	}
 	return filteredResult
 }
+
+// Returns the number of valid moves, i.e. len(f.ValidMoves()), but without
+// building the moves when the side to move isn't in check, which makes it
+// cheaper for counting leaf nodes and mobility.
+func (f *Game) CountValidMoves() int {
+	if f.valid != nil {
+		return len(*f.valid)
+	}
+	color := f.ToMove
+	if len(f.validMoves.GetChecks(color, f.Pieces)) > 0 {
+		return len(f.ValidMoves())
+	}
+	kingPos := f.Pieces.GetKingPos(color)
+	pinned := f.SquareControl.GetPinnedPieces(f.Board, color, kingPos)
+	count := 0
+	for from := Position(0); from < 64; from++ {
+		piece := f.Board[from]
+		if piece == NoPiece || piece.Color() != color {
+			continue
+		}
+		normPiece := piece.ToNormalizedPiece()
+		targets := f.validMoves[from]
+		attackers := pinned[from]
+		if len(attackers) > 0 && normPiece == Knight {
+			continue
+		}
+		for to := Position(0); to < 64; to++ {
+			if !targets.IsSet(to) {
+				continue
+			}
+			if normPiece == King && f.SquareControl.AttacksSquare(color.Opposite(), to) {
+				targets = targets.Remove(to)
+			} else if normPiece == Pawn && to == f.EnPassantVulnerable && f.isEnPassantPinned(color, from) {
+				targets = targets.Remove(to)
+			} else if len(attackers) > 0 {
+				attackVector := NewMove(from, attackers[0]).Vector().Normalize()
+				vector := NewMove(from, to).Vector().Normalize()
+				if !attackVector.Eq(vector) && !attackVector.Eq(vector.Invert()) {
+					targets = targets.Remove(to)
+				}
+			}
+		}
+		if normPiece == Pawn && (from.GetRank() == '2' && color == Black || from.GetRank() == '7' && color == White) {
+			count += 4 * targets.Count()
+		} else {
+			count += targets.Count()
+		}
+	}
+	for _, side := range []CastleStatus{Kingside, Queenside} {
+		canCastle := f.CastleStatuses.CanCastleKingside(color)
+		if side == Queenside {
+			canCastle = f.CastleStatuses.CanCastleQueenside(color)
+		}
+		if canCastle && f.CastlingPathClear(color, side) && f.CastlingPathSafe(color, side) {
+			count++
+		}
+	}
+	return count
+}
+
 func (f *Game) ValidMoves() []*Move {
 	if f.valid != nil {
 		return *f.valid
 	}
 	result := f.GetValidMovesForColor(f.ToMove)
+	// Sort the moves so that searches are reproducible, no matter in what
+	// order the moves were generated.
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].From != result[j].From {
+			return result[i].From < result[j].From
+		}
+		if result[i].To != result[j].To {
+			return result[i].To < result[j].To
+		}
+		return result[i].Promote < result[j].Promote
+	})
 	f.valid = &result
 	return result
 }
 
+// Returns the valid moves for the piece on @pos, e.g. to highlight
+// destination squares in a UI.
+func (f *Game) ValidMovesForPiece(pos Position) []*Move {
+	result := []*Move{}
+	for _, move := range f.ValidMoves() {
+		if move.From == pos {
+			result = append(result, move)
+		}
+	}
+	return result
+}
+
+// Whether @move takes a piece, including en passant captures.
+func (f *Game) IsCapture(move *Move) bool {
+	return f.Board[move.To] != NoPiece || move.GetEnPassantCapture(f.Board[move.From], f.EnPassantVulnerable) != nil
+}
+
+// Returns the valid moves that capture a piece, so that a search can look at
+// them before the quiet moves without having to sort all the moves. The
+// captures are looked up in the SquareControl instead of generating all the
+// moves, unless we're in check, where ValidMoves already has to do the
+// work of finding the moves that get us out of it.
+func (f *Game) ValidCaptures() []*Move {
+	result := []*Move{}
+	if f.valid != nil || f.InCheck() {
+		for _, move := range f.ValidMoves() {
+			if f.IsCapture(move) {
+				result = append(result, move)
+			}
+		}
+		return result
+	}
+	opponent := f.ToMove.Opposite()
+	for _, move := range f.SquareControl.GetCaptures(f.ToMove, f.Board) {
+		// The king can't take a defended piece
+		if f.Board[move.From].ToNormalizedPiece() == King && f.SquareControl.AttacksSquare(opponent, move.To) {
+			continue
+		}
+		result = append(result, move)
+	}
+	if f.EnPassantVulnerable != NoPosition && f.canCaptureEnPassant() {
+		for _, pos := range f.EnPassantVulnerable.GetPawnAttacks(opponent) {
+			if f.Board[pos] == Pawn.ToPiece(f.ToMove) && !f.isEnPassantPinned(f.ToMove, pos) {
+				result = append(result, NewMove(pos, f.EnPassantVulnerable))
+			}
+		}
+	}
+	return f.FilterPinnedPieces(result)
+}
+
+// Returns the valid moves that don't capture anything, including castles and
+// promotions on an empty square.
+func (f *Game) ValidQuietMoves() []*Move {
+	result := []*Move{}
+	for _, move := range f.ValidMoves() {
+		if !f.IsCapture(move) {
+			result = append(result, move)
+		}
+	}
+	return result
+}
+
+// Whether playing @move puts the opponent in check, either directly or by
+// moving out of the way of one of our sliders.
+func (f *Game) GivesCheck(move *Move) bool {
+	return f.ApplyMove(move).InCheck()
+}
+
+// Returns the valid moves that put the opponent in check.
+func (f *Game) MovesThatGiveCheck() []*Move {
+	result := []*Move{}
+	for _, move := range f.ValidMoves() {
+		if f.GivesCheck(move) {
+			result = append(result, move)
+		}
+	}
+	return result
+}
+
+// Whether @move is legal for the side to move. This is cheaper than looking
+// the move up in ValidMoves when validating a single move, e.g. one sent by
+// a GUI, because only the moving piece is looked at. Only pawns reaching the
+// last rank can promote, to a piece of their own colour that isn't a pawn or
+// a king.
+func (f *Game) IsLegalMove(move *Move) bool {
+	piece := f.Board[move.From]
+	if piece == NoPiece || piece.Color() != f.ToMove || f.Board.IsColor(move.To, f.ToMove) {
+		return false
+	}
+	normPiece := piece.ToNormalizedPiece()
+	rank := move.To.GetRank()
+	if normPiece == Pawn && (rank == '1' || rank == '8') {
+		promote := move.Promote.ToNormalizedPiece()
+		if move.Promote == NoPiece || move.Promote.Color() != f.ToMove || promote == Pawn || promote == King {
+			return false
+		}
+	} else if move.Promote != NoPiece {
+		return false
+	}
+	if move.GetRookCastlesMove(piece) != nil {
+		side := Kingside
+		if move.To.GetFile() == 'c' {
+			side = Queenside
+			if !f.CastleStatuses.CanCastleQueenside(f.ToMove) {
+				return false
+			}
+		} else if !f.CastleStatuses.CanCastleKingside(f.ToMove) {
+			return false
+		}
+		return f.CastlingPathClear(f.ToMove, side) && f.CastlingPathSafe(f.ToMove, side)
+	}
+	// The valid moves list knows where every piece can go, taking blocking
+	// pieces into account, but not whether that leaves the king in check.
+	if !f.validMoves[move.From].IsSet(move.To) {
+		return false
+	}
+	next := f.ApplyMove(move)
+	return !next.SquareControl.AttacksSquare(f.ToMove.Opposite(), next.Pieces.GetKingPos(f.ToMove))
+}
+
 func (f *Game) GetValidMovesForColor(color Color) []*Move {
 
 	checks := f.validMoves.GetChecks(color, f.Pieces)
@@ -283,6 +769,8 @@ func (f *Game) GetValidMovesForColor(color Color) []*Move {
 		// The king can only move to squares that are empty and/or unattacked
 		if f.Board[move.From].ToNormalizedPiece() == King && f.SquareControl.AttacksSquare(color.Opposite(), move.To) {
 			// Filtering invalid king move
+		} else if move.GetEnPassantCapture(f.Board[move.From], f.EnPassantVulnerable) != nil && f.isEnPassantPinned(color, move.From) {
+			// Filtering en passant capture that would leave the king in check
 		} else {
 			result = append(result, move)
 		}
@@ -290,64 +778,24 @@ func (f *Game) GetValidMovesForColor(color Color) []*Move {
 
 	kingPos := f.Pieces.GetKingPos(color)
 
-	if f.EnPassantVulnerable != NoPosition {
-		for _, pos := range f.EnPassantVulnerable.GetPawnAttacks(f.ToMove.Opposite()) {
-			if f.Board[pos] == Pawn.ToPiece(f.ToMove) {
-				// Skip if this puts us in check, which can happen when the king
-				// is on the same rank.
-				pinned := false
-				if kingPos.GetRank() == pos.GetRank() {
-					leftPawn, rightPawn := pos, f.EnPassantVulnerable.GetEnPassantCapture()
-					if leftPawn.GetFile() > rightPawn.GetFile() {
-						leftPawn, rightPawn = rightPawn, leftPawn
-					}
-					possiblyPinned := false
-					otherPawn := leftPawn
-					if kingPos.GetFile() > pos.GetFile() {
-						// King is on the right
-						possiblyPinned = f.Board.HasClearLineTo(rightPawn, kingPos)
-						otherPawn = leftPawn
-					} else {
-						// King is on the left
-						possiblyPinned = f.Board.HasClearLineTo(leftPawn, kingPos)
-						otherPawn = rightPawn
-					}
-					if possiblyPinned {
-						// Is there an attack on the other pawn from the same rank?
-						for _, att := range f.SquareControl.GetAttacksOnSquare(color.Opposite(), otherPawn) {
-							if att.From.GetRank() == pos.GetRank() {
-								pinned = true
-							}
-						}
-					}
-
-				}
-				if !pinned {
-					result = append(result, NewMove(pos, f.EnPassantVulnerable))
-				}
-			}
-		}
-
-	}
-
 	// Castling
 	if color == White && f.CastleStatuses.CanCastleQueenside(White) {
-		if f.Board.CanCastle(f.SquareControl, White, C1, D1) && f.Board.IsEmpty(B1) {
+		if f.CastlingPathClear(White, Queenside) && f.CastlingPathSafe(White, Queenside) {
 			result = append(result, NewMove(kingPos, C1))
 		}
 	}
 	if color == White && f.CastleStatuses.CanCastleKingside(White) {
-		if f.Board.CanCastle(f.SquareControl, White, F1, G1) {
+		if f.CastlingPathClear(White, Kingside) && f.CastlingPathSafe(White, Kingside) {
 			result = append(result, NewMove(kingPos, G1))
 		}
 	}
 	if color == Black && f.CastleStatuses.CanCastleQueenside(Black) {
-		if f.Board.CanCastle(f.SquareControl, Black, C8, D8) && f.Board.IsEmpty(B8) {
+		if f.CastlingPathClear(Black, Queenside) && f.CastlingPathSafe(Black, Queenside) {
 			result = append(result, NewMove(kingPos, C8))
 		}
 	}
 	if color == Black && f.CastleStatuses.CanCastleKingside(Black) {
-		if f.Board.CanCastle(f.SquareControl, Black, F8, G8) {
+		if f.CastlingPathClear(Black, Kingside) && f.CastlingPathSafe(Black, Kingside) {
 			result = append(result, NewMove(kingPos, G8))
 		}
 	}
@@ -356,6 +804,94 @@ func (f *Game) GetValidMovesForColor(color Color) []*Move {
 	return f.FilterPinnedPieces(result)
 }
 
+// Whether all the squares between @color's king and rook are empty, so
+// that the king can castle to @side as far as the other pieces are concerned.
+func (f *Game) CastlingPathClear(color Color, side CastleStatus) bool {
+	path := []Position{}
+	if side == Kingside {
+		path = []Position{F1, G1}
+	} else if side == Queenside {
+		path = []Position{B1, C1, D1}
+	}
+	for _, pos := range path {
+		if color == Black {
+			pos += 56
+		}
+		if !f.Board.IsEmpty(pos) {
+			return false
+		}
+	}
+	return len(path) > 0
+}
+
+// Whether @color's king can castle to @side without starting in, passing
+// through or ending up in check. The rook is allowed to pass through an
+// attacked square, so b1 and b8 aren't checked.
+func (f *Game) CastlingPathSafe(color Color, side CastleStatus) bool {
+	path := []Position{}
+	if side == Kingside {
+		path = []Position{E1, F1, G1}
+	} else if side == Queenside {
+		path = []Position{E1, D1, C1}
+	}
+	for _, pos := range path {
+		if color == Black {
+			pos += 56
+		}
+		if f.SquareControl.AttacksSquare(color.Opposite(), pos) {
+			return false
+		}
+	}
+	return len(path) > 0
+}
+
+// Returns the square of the pawn that can be captured en passant, which is
+// the square in front of the en passant target from the perspective of the
+// side to move, or NoPosition if there's no en passant capture.
+func (f *Game) EnPassantCaptureSquare() Position {
+	if f.EnPassantVulnerable == NoPosition {
+		return NoPosition
+	}
+	if f.ToMove == White {
+		return f.EnPassantVulnerable - 8
+	}
+	return f.EnPassantVulnerable + 8
+}
+
+// Whether capturing en passant with the pawn on @pos would put @color's king
+// in check, which can happen when the king is on the same rank as both pawns.
+// Other pins are handled by FilterPinnedPieces.
+func (f *Game) isEnPassantPinned(color Color, pos Position) bool {
+	kingPos := f.Pieces.GetKingPos(color)
+	if kingPos.GetRank() != pos.GetRank() {
+		return false
+	}
+	leftPawn, rightPawn := pos, f.EnPassantCaptureSquare()
+	if leftPawn.GetFile() > rightPawn.GetFile() {
+		leftPawn, rightPawn = rightPawn, leftPawn
+	}
+	possiblyPinned := false
+	otherPawn := leftPawn
+	if kingPos.GetFile() > pos.GetFile() {
+		// King is on the right
+		possiblyPinned = f.Board.HasClearLineTo(rightPawn, kingPos)
+		otherPawn = leftPawn
+	} else {
+		// King is on the left
+		possiblyPinned = f.Board.HasClearLineTo(leftPawn, kingPos)
+		otherPawn = rightPawn
+	}
+	if possiblyPinned {
+		// Is there an attack on the other pawn from the same rank?
+		for _, att := range f.SquareControl.GetAttacksOnSquare(color.Opposite(), otherPawn) {
+			if att.From.GetRank() == pos.GetRank() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (f *Game) ApplyMove(move *Move) *Game {
 	result := &Game{}
 	line := make([]*Move, len(f.Line)+1)
@@ -366,7 +902,8 @@ func (f *Game) ApplyMove(move *Move) *Game {
 
 	board := f.Board.Copy()
 
-	capturedPiece := board.ApplyMove(move.From, move.To).ToNormalizedPiece()
+	lastCaptured := board.ApplyMove(move.From, move.To)
+	capturedPiece := lastCaptured.ToNormalizedPiece()
 	movingPiece := board[move.To]
 	if movingPiece == NoPiece {
 		fmt.Println(f.Board)
@@ -389,6 +926,13 @@ func (f *Game) ApplyMove(move *Move) *Game {
 		}
 		board.ApplyMove(castles.From, castles.To)
 	}
+	// Remove the pawn that was captured by en-passant
+	enpassantCapture := NoPosition
+	if move.GetEnPassantCapture(movingPiece, f.EnPassantVulnerable) != nil {
+		enpassantCapture = f.EnPassantCaptureSquare()
+		board[enpassantCapture] = NoPiece
+		lastCaptured = Pawn.ToPiece(f.ToMove.Opposite())
+	}
 	enpassant := NoPosition
 	switch movingPiece {
 	case WhitePawn:
@@ -402,9 +946,6 @@ func (f *Game) ApplyMove(move *Move) *Game {
 					enpassant = enpassantSquare
 				}
 			}
-		} else if move.To == f.EnPassantVulnerable {
-			// Remove the pawn that was captured by en-passant
-			board[move.To-8] = NoPiece
 		}
 	case BlackPawn:
 		if move.From.GetRank() == '7' && move.To.GetRank() == '5' {
@@ -417,17 +958,13 @@ func (f *Game) ApplyMove(move *Move) *Game {
 					enpassant = enpassantSquare
 				}
 			}
-		} else if move.To == f.EnPassantVulnerable {
-			// Remove the pawn that was captured by en-passant
-			board[move.To+8] = NoPiece
 		}
 	}
 
 	result.Board = board
 	result.Pieces = f.Pieces.ApplyMove(f.ToMove, move, normalizedMovingPiece, capturedPiece)
-	enpassantCapture := move.GetEnPassantCapture(movingPiece, f.EnPassantVulnerable)
-	if enpassantCapture != nil {
-		result.Pieces.RemovePosition(Pawn.ToPiece(f.ToMove.Opposite()), *enpassantCapture)
+	if enpassantCapture != NoPosition {
+		result.Pieces.RemovePosition(Pawn.ToPiece(f.ToMove.Opposite()), enpassantCapture)
 	}
 
 	result.SquareControl = f.SquareControl.ApplyMove(move, movingPiece, f.Board[move.To], board, f.EnPassantVulnerable)
@@ -447,13 +984,137 @@ func (f *Game) ApplyMove(move *Move) *Game {
 	result.HalfmoveClock = halfMove
 	result.Fullmove = fullMove
 	result.Line = line
+	result.lastCaptured = lastCaptured
 	result.Parent = f
 
-	result.validMoves = f.validMoves.ApplyMove(move, movingPiece, board, f.EnPassantVulnerable, result.Pieces)
+	result.validMoves = f.validMoves.ApplyMove(move, movingPiece, board, f.EnPassantVulnerable, enpassant, result.Pieces)
+
+	// The SquareControl tells us straight away if the move gave check
+	if !result.Pieces[result.ToMove][King].IsEmpty() {
+		inCheck := result.SquareControl.AttacksSquare(f.ToMove, result.Pieces.GetKingPos(result.ToMove))
+		result.inCheck = &inCheck
+	}
 
 	return result
 }
 
+// Returns the move that produced this position, or nil if the Line is empty.
+func (f *Game) LastMove() *Move {
+	if len(f.Line) == 0 {
+		return nil
+	}
+	return f.Line[len(f.Line)-1]
+}
+
+// Returns the piece that was captured by the LastMove, including pawns
+// captured en passant, or NoPiece if it wasn't a capture.
+func (f *Game) LastCapturedPiece() Piece {
+	if f.LastMove() == nil {
+		return NoPiece
+	}
+	return f.lastCaptured
+}
+
+// Returns a copy of the game that can be used as the starting position of a
+// search. The search expects the Line to only contain the moves it has made
+// itself, so it's cleared; the Parent is kept so repetitions are still found.
+func (f *Game) AsSearchRoot() *Game {
+	result := *f
+	result.Line = nil
+	result.Score = nil
+	result.nextGames = nil
+	return &result
+}
+
+// Sets the castling rights from the positions of the kings and rooks, so
+// that a side can castle on every side where its king and rook are still on
+// their home squares. Useful when building positions without a FEN string,
+// because there's no way of telling whether the pieces have moved before.
+func (f *Game) SetCastlingFromKingRookPositions() {
+	castleStatus := func(color Color, king, queensideRook, kingsideRook Position) CastleStatus {
+		if f.Board[king] != King.ToPiece(color) {
+			return None
+		}
+		queenside := f.Board[queensideRook] == Rook.ToPiece(color)
+		kingside := f.Board[kingsideRook] == Rook.ToPiece(color)
+		if queenside && kingside {
+			return Both
+		} else if queenside {
+			return Queenside
+		} else if kingside {
+			return Kingside
+		}
+		return None
+	}
+	f.CastleStatuses = NewCastleStatuses(castleStatus(White, E1, A1, H1), castleStatus(Black, E8, A8, H8))
+	f.valid = nil
+	f.Score = nil
+	f.nextGames = nil
+	f.hash = 0
+}
+
+// Returns a copy of the game with @color to move, e.g. to see what the
+// opponent would do if it were their turn. The en passant square is cleared,
+// because passing forfeits the right to capture en passant.
+func (f *Game) WithSideToMove(color Color) *Game {
+	result := *f
+	result.ToMove = color
+	result.inCheck = nil
+	if f.EnPassantVulnerable != NoPosition {
+		result.validMoves = f.validMoves.Copy()
+		result.validMoves.RemoveEnPassantCaptures(f.EnPassantVulnerable, f.ToMove, f.Board)
+		result.EnPassantVulnerable = NoPosition
+	}
+	result.valid = nil
+	result.Score = nil
+	result.nextGames = nil
+	result.hash = 0
+	return &result
+}
+
+// Passes the turn to the opponent, e.g. for null move pruning. Returns the
+// new game and the en passant square that was forfeited, so that
+// UnmakeNullMove can restore it. The original game isn't changed.
+func (f *Game) MakeNullMove() (*Game, Position) {
+	result := f.WithSideToMove(f.ToMove.Opposite())
+	result.HalfmoveClock++
+	if f.ToMove == Black {
+		result.Fullmove++
+	}
+	return result, f.EnPassantVulnerable
+}
+
+// Takes back a null move made by MakeNullMove, restoring the @enpassant
+// square it returned.
+func (f *Game) UnmakeNullMove(enpassant Position) *Game {
+	result := f.WithSideToMove(f.ToMove.Opposite())
+	result.HalfmoveClock--
+	if f.ToMove == White {
+		result.Fullmove--
+	}
+	if enpassant != NoPosition {
+		result.validMoves = result.validMoves.Copy()
+		result.validMoves.AddEnPassantCaptures(enpassant, result.ToMove, result.Board)
+		result.EnPassantVulnerable = enpassant
+	}
+	return result
+}
+
+// Whether the two games describe the same position, including the clocks.
+func (f *Game) Equal(other *Game) bool {
+	return f.FENString() == other.FENString()
+}
+
+// Returns the number of half moves since the start of the game, derived
+// from the Fullmove counter and the side to move.
+func (f *Game) Ply() int {
+	ply := (f.Fullmove - 1) * 2
+	if f.ToMove == Black {
+		ply++
+	}
+	return ply
+}
+
 func (f *Game) Phase() int {
 	return f.Pieces.Phase()
 }
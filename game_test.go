@@ -2,6 +2,7 @@ package chess_engine
 
 import (
 	"fmt"
+	"math/rand"
 	"strings"
 	"testing"
 )
@@ -295,8 +296,7 @@ func Test_ApplyMove_promote(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	move := NewMove(A7, A8)
-	move.Promote = WhiteQueen
+	move := NewPromotionMove(A7, A8, WhiteQueen)
 	fen := unit.ApplyMove(move)
 	if fen.Board[A8] != WhiteQueen {
 		t.Errorf("Expecting a white queen on a8")
@@ -320,8 +320,7 @@ func Test_ApplyMove_promote_black(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	move := NewMove(A2, A1)
-	move.Promote = BlackQueen
+	move := NewPromotionMove(A2, A1, BlackQueen)
 	fen := unit.ApplyMove(move)
 	if fen.Board[A1] != BlackQueen {
 		t.Errorf("Expecting a black queen on a1")
@@ -568,6 +567,14 @@ func Test_Perft4(t *testing.T) {
 	runPerftTests(t, "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", perft, checks)
 }
 
+func Test_Perft4_mirrored(t *testing.T) {
+	if !isTestEnabled(t, "INTEGRATION", "PERFT", "PERFT4") {
+		return
+	}
+	perft := []int{6, 264, 9467, 422333}
+	runPerftTests(t, "r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1", perft, nil)
+}
+
 func Test_Perft5(t *testing.T) {
 	if !isTestEnabled(t, "INTEGRATION", "PERFT", "PERFT5") {
 		return
@@ -576,6 +583,16 @@ func Test_Perft5(t *testing.T) {
 	runPerftTests(t, "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", perft, nil)
 }
 
+// The shallow depths of the perft suite are cheap enough to always run, and
+// still cover partial castling rights, promotions and en passant from
+// positions other than the starting position.
+func Test_Perft_from_arbitrary_positions(t *testing.T) {
+	runPerftTests(t, "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", []int{6, 264, 9467}, nil)
+	runPerftTests(t, "r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1", []int{6, 264, 9467}, nil)
+	runPerftTests(t, "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []int{44, 1486}, nil)
+	runPerftTests(t, "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", []int{14, 191, 2812}, nil)
+}
+
 func Test_Perft6(t *testing.T) {
 	if !isTestEnabled(t, "INTEGRATION", "PERFT", "PERFT6") {
 		return
@@ -594,3 +611,1652 @@ func Benchmark_ApplyMove(t *testing.B) {
 		unit.ApplyMove(NewMove(E2, E4))
 	}
 }
+
+func Benchmark_Perft3(t *testing.B) {
+	unit, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.ReportAllocs()
+	t.ResetTimer()
+	for i := 0; i < t.N; i++ {
+		// Using a different maxdepth so the move breakdown isn't printed
+		perft(unit, -1, 3)
+	}
+}
+
+func Test_ValidMovesForPiece(t *testing.T) {
+	unit, err := ParseFEN("4r2k/8/8/8/8/8/4N3/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moves := unit.ValidMovesForPiece(E2); len(moves) != 0 {
+		t.Errorf("Expecting no moves for pinned knight, got %v", moves)
+	}
+	unit, err = ParseFEN("7k/8/8/8/8/8/4N3/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := unit.ValidMovesForPiece(E2)
+	if len(moves) != 6 {
+		t.Errorf("Expecting six moves for unpinned knight, got %v", moves)
+	}
+	for _, m := range moves {
+		if m.From != E2 {
+			t.Errorf("Expecting only moves from e2, got %s", m)
+		}
+	}
+}
+
+func Test_ValidMoves_en_passant_is_the_only_move(t *testing.T) {
+	cases := []string{
+		// not in check, every other move is blocked
+		"7k/8/4p3/3pP3/8/8/2q5/K7 w - d6 0 1",
+		// in check by the pawn that just jumped
+		"7k/8/p1n5/1pP5/K7/7r/8/8 w - b6 0 1",
+	}
+	for _, fenStr := range cases {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.IsMate() {
+			t.Errorf("Not expecting mate in %s", fenStr)
+		}
+		if unit.IsDraw() {
+			t.Errorf("Not expecting stalemate in %s", fenStr)
+		}
+		moves := unit.ValidMoves()
+		if len(moves) != 1 || moves[0].To != unit.EnPassantVulnerable {
+			t.Errorf("Expecting en passant as the only valid move in %s, got %v", fenStr, moves)
+		}
+	}
+}
+
+func Test_ValidMoves_en_passant_gives_check(t *testing.T) {
+	cases := []string{
+		// the capturing pawn checks the king
+		"8/2k5/8/3pP3/8/8/8/4K3 w - d6 0 1",
+		// removing the captured pawn opens the diagonal for the bishop
+		"6k1/8/8/3pP3/8/8/B7/4K3 w - d6 0 1",
+	}
+	for _, fenStr := range cases {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, move := range unit.ValidMoves() {
+			if move.String() == "e5d6" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expecting en passant to be valid in %s", fenStr)
+		}
+		if !unit.GivesCheck(MustParseMove("e5d6")) {
+			t.Errorf("Expecting en passant to give check in %s", fenStr)
+		}
+		result, err := ParseFEN(unit.ApplyMove(MustParseMove("e5d6")).FENString())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.InCheck() {
+			t.Errorf("Expecting black to be in check after en passant in %s", fenStr)
+		}
+	}
+}
+
+func Test_ApplyMove_promote_updates_piece_positions(t *testing.T) {
+	unit, err := ParseFEN("1r5k/P7/8/8/8/8/1P6/K2Q4 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queens, pawns, rooks := unit.Pieces[White][Queen].Count(), unit.Pieces[White][Pawn].Count(), unit.Pieces[Black][Rook].Count()
+	fen := unit.ApplyMove(NewPromotionMove(A7, B8, WhiteQueen))
+	if fen.Pieces[White][Queen].Count() != queens+1 {
+		t.Errorf("Expecting %d white queens, got %d", queens+1, fen.Pieces[White][Queen].Count())
+	}
+	if fen.Pieces[White][Pawn].Count() != pawns-1 {
+		t.Errorf("Expecting %d white pawns, got %d", pawns-1, fen.Pieces[White][Pawn].Count())
+	}
+	if fen.Pieces[White][Pawn].IsSet(B8) || fen.Pieces[White][Pawn].IsSet(A7) {
+		t.Errorf("Not expecting a white pawn on a7 or b8")
+	}
+	if fen.Pieces[Black][Rook].Count() != rooks-1 {
+		t.Errorf("Expecting the black rook to be captured")
+	}
+	if !fen.Pieces.HasPiecePosition(WhiteQueen, B8) {
+		t.Errorf("Expecting a white queen on b8")
+	}
+}
+
+func Test_ApplyMove_en_passant_removes_captured_pawn(t *testing.T) {
+	cases := [][]string{
+		[]string{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", "d5", "4k3/8/3P4/8/8/8/8/4K3 b - - 0 1"},
+		[]string{"4k3/8/8/8/3pP3/8/8/4K3 b - e3 0 1", "d4e3", "e4", "4k3/8/8/8/8/4p3/8/4K3 w - - 0 2"},
+		[]string{"4k3/2p5/8/3P4/8/8/8/4K3 b - - 0 1", "c7c5 d5c6", "c5", "4k3/8/2P5/8/8/8/8/4K3 b - - 0 2"},
+	}
+	for _, testCase := range cases {
+		unit, err := ParseFEN(testCase[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		moves := strings.Fields(testCase[1])
+		for _, move := range moves[:len(moves)-1] {
+			unit = unit.ApplyMove(MustParseMove(move))
+		}
+		opponent := unit.ToMove.Opposite()
+		pawns := unit.Pieces[opponent][Pawn].Count()
+		captured := MustParsePosition(testCase[2])
+		fen := unit.ApplyMove(MustParseMove(moves[len(moves)-1]))
+		if fen.FENString() != testCase[3] {
+			t.Errorf("Expecting %s after %s, got %s", testCase[3], testCase[1], fen.FENString())
+		}
+		if fen.LastCapturedPiece() != Pawn.ToPiece(opponent) {
+			t.Errorf("Expecting a %s pawn to be captured by %s, got %s", opponent, testCase[1], fen.LastCapturedPiece())
+		}
+		if fen.Pieces[opponent][Pawn].Count() != pawns-1 {
+			t.Errorf("Expecting %d %s pawns after %s, got %d", pawns-1, opponent, testCase[1], fen.Pieces[opponent][Pawn].Count())
+		}
+		if fen.Pieces[opponent][Pawn].IsSet(captured) {
+			t.Errorf("Expecting the captured pawn on %s to be removed from the pieces", captured)
+		}
+		if fen.Board[captured] != NoPiece {
+			t.Errorf("Expecting the captured pawn on %s to be removed from the board", captured)
+		}
+	}
+}
+
+func Test_IsCheckmate(t *testing.T) {
+	cases := map[string]bool{
+		"rn2k2r/1p3ppp/2p5/1p2p3/2P1n1bP/P5P1/4p2R/b1B1K1q1 w kq - 36 1":      true,
+		"r4b2/p3pB2/3N4/6Q1/6kp/P1N1B3/1PP2PPP/R3K2R b KQ - 45 1":             true,
+		"r3kb1r/pp3ppp/2n2n2/3p4/Pq3pbP/1P2pK2/1BPPP1P1/RN1Q1B2 w kq - 22 12": true,
+		// the king can escape to f1
+		"4k3/8/8/8/8/8/8/r3K3 w - - 0 1": false,
+		// the king can capture the checking piece
+		"4k3/8/8/8/8/8/3PPP2/3rK3 w - - 0 1": false,
+		// stalemate is not checkmate
+		"3R4/2B1k3/8/4N1P1/PPB4P/8/2P5/4K3 b - - 0 35": false,
+	}
+	for fenStr, expected := range cases {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.IsCheckmate() != expected {
+			t.Errorf("Expecting IsCheckmate() to be %v for %s, valid moves: %v", expected, fenStr, unit.ValidMoves())
+		}
+		if unit.IsMate() != unit.IsCheckmate() {
+			t.Errorf("Expecting IsMate() to agree with IsCheckmate() for %s", fenStr)
+		}
+	}
+}
+
+func Test_IsStalemate(t *testing.T) {
+	cases := map[string]bool{
+		"3R4/2B1k3/8/4N1P1/PPB4P/8/2P5/4K3 b - - 0 35": true,
+		"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1":               true,
+		"k7/P7/K7/8/8/8/8/8 b - - 0 1":                 true,
+		// checkmate isn't stalemate
+		"R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1": false,
+		// in check, but the king can escape
+		"4k3/8/8/8/8/8/8/r3K3 w - - 0 1":                           false,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1": false,
+	}
+	for fenStr, expected := range cases {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.IsStalemate() != expected {
+			t.Errorf("Expecting IsStalemate() to be %v for %s", expected, fenStr)
+		}
+		if expected && !unit.IsDraw() {
+			t.Errorf("Expecting stalemate to be a draw in %s", fenStr)
+		}
+		if unit.IsStalemate() && unit.IsCheckmate() {
+			t.Errorf("Not expecting both stalemate and checkmate in %s", fenStr)
+		}
+	}
+	// Reaching stalemate in a line
+	unit, err := ParseFEN("7k/8/6K1/8/8/8/8/5Q2 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := unit.ApplyMove(MustParseMove("f1f7")); !result.IsStalemate() {
+		t.Errorf("Expecting stalemate after Qf7")
+	}
+}
+
+func Test_ParseFEN_side_to_move(t *testing.T) {
+	cases := map[string]Color{
+		"4k3/8/8/8/8/8/8/4K3 w - - 0 1": White,
+		"4k3/8/8/8/8/8/8/4K3 W - - 0 1": White,
+		"4k3/8/8/8/8/8/8/4K3 b - - 0 1": Black,
+		"4k3/8/8/8/8/8/8/4K3 B - - 0 1": Black,
+	}
+	for fenStr, expected := range cases {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.ToMove != expected {
+			t.Errorf("Expecting %s to move in %s, got %s", expected, fenStr, unit.ToMove)
+		}
+	}
+	if _, err := ParseFEN("4k3/8/8/8/8/8/8/4K3 x - - 0 1"); err == nil {
+		t.Errorf("Expecting an error for an invalid side to move")
+	}
+}
+
+func Test_ApplyMove_opening_jump_adds_en_passant_capture(t *testing.T) {
+	unit, err := ParseFEN("4k3/3p4/8/4P3/8/8/8/4K3 b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fen := unit.ApplyMove(NewMove(D7, D5))
+	if fen.EnPassantVulnerable != D6 {
+		t.Fatalf("Expecting d6 to be vulnerable, got %s", fen.EnPassantVulnerable)
+	}
+	if !fen.validMoves[E5].IsSet(D6) {
+		t.Errorf("Expecting e5d6 in the valid moves list for e5, got %v", fen.validMoves[E5].ToPositions())
+	}
+	next := fen.ApplyMove(NewMove(E1, E2))
+	if next.validMoves[E5].IsSet(D6) {
+		t.Errorf("Not expecting e5d6 in the valid moves list after the en passant capture expired")
+	}
+	for _, m := range next.ValidMoves() {
+		if m.From == E5 && m.To == D6 {
+			t.Errorf("Not expecting en passant capture after it expired")
+		}
+	}
+}
+
+func Test_RepetitionCountInLine(t *testing.T) {
+	unit, err := ParseFEN("7k/6p1/8/5Q2/r7/r7/1q6/7K w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []string{"f5f8", "h8h7", "f8f5", "h7h8", "f5f8", "h8h7", "f8f5", "h7h8"}
+	game := unit
+	for i, moveStr := range line {
+		game = game.ApplyMove(MustParseMove(moveStr))
+		expected := (i+1)/4 + 1
+		if game.RepetitionCountInLine() != expected {
+			t.Errorf("Expecting %d occurrences after %s, got %d", expected, Line(game.Line), game.RepetitionCountInLine())
+		}
+		if game.IsDraw() != (expected == 3) {
+			t.Errorf("Expecting IsDraw() to be %v after %s", expected == 3, Line(game.Line))
+		}
+	}
+	// Black is a queen and two rooks up, but the perpetual is a draw
+	score, _ := Evaluators([]Evaluator{NaiveMaterialEvaluator}).Eval(game)
+	if score != Draw {
+		t.Errorf("Expecting the perpetual to be scored as a draw, got %d", score)
+	}
+}
+
+func Test_Ply(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.Ply() != 0 {
+		t.Errorf("Expecting ply 0 in the starting position, got %d", unit.Ply())
+	}
+	unit = unit.ApplyMove(NewMove(E2, E4))
+	if unit.Ply() != 1 {
+		t.Errorf("Expecting ply 1 after 1.e4, got %d", unit.Ply())
+	}
+	unit = unit.ApplyMove(NewMove(E7, E5))
+	if unit.Ply() != 2 {
+		t.Errorf("Expecting ply 2 after 1...e5, got %d", unit.Ply())
+	}
+	unit, err = ParseFEN("4k3/8/8/8/8/8/8/4K3 b - - 0 12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.Ply() != 23 {
+		t.Errorf("Expecting ply 23, got %d", unit.Ply())
+	}
+}
+
+func Test_Board_FlipVertical_twice_is_identity(t *testing.T) {
+	unit, err := ParseFEN("r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flipped := unit.Board.FlipVertical()
+	if flipped[A8] != WhiteRook || flipped[A1] != BlackRook {
+		t.Errorf("Expecting the rooks to swap ranks")
+	}
+	flipped = flipped.FlipVertical()
+	for pos, piece := range unit.Board {
+		if flipped[pos] != piece {
+			t.Errorf("Expecting %s on %s, got %s", piece, Position(pos), flipped[pos])
+		}
+	}
+}
+
+func Test_Board_SwapColors(t *testing.T) {
+	unit, err := ParseFEN("4k3/8/8/8/8/8/4P3/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := unit.Board.SwapColors()
+	if swapped[E2] != BlackPawn || swapped[E1] != BlackKing || swapped[E8] != WhiteKing {
+		t.Errorf("Expecting colours to be swapped, got\n%s", swapped)
+	}
+	mirrored := unit.Board.FlipVertical().SwapColors()
+	if mirrored[E7] != BlackPawn || mirrored[E2] != NoPiece {
+		t.Errorf("Expecting the pawn on e7, got\n%s", mirrored)
+	}
+}
+
+func Test_Game_Mirror(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b Kq e3 0 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirrored := unit.Mirror()
+	expected := "rnbqkbnr/pppp1ppp/8/3Pp3/8/8/PPP1PPPP/RNBQKBNR w Qk e6 0 3"
+	if mirrored.FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, mirrored.FENString())
+	}
+	if len(mirrored.ValidMoves()) != len(unit.ValidMoves()) {
+		t.Errorf("Expecting the same number of valid moves, got %d and %d", len(mirrored.ValidMoves()), len(unit.ValidMoves()))
+	}
+	if mirrored.Mirror().FENString() != unit.FENString() {
+		t.Errorf("Expecting mirroring twice to give %s, got %s", unit.FENString(), mirrored.Mirror().FENString())
+	}
+}
+
+func Test_ValidMoves_are_sorted(t *testing.T) {
+	pos := "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"
+	unit, err := ParseFEN(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := unit.ValidMoves()
+	for i := 1; i < len(moves); i++ {
+		if moves[i-1].From > moves[i].From || (moves[i-1].From == moves[i].From && moves[i-1].To > moves[i].To) {
+			t.Errorf("Expecting %s before %s", moves[i], moves[i-1])
+		}
+	}
+	again, err := ParseFEN(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, move := range again.ValidMoves() {
+		if move.String() != moves[i].String() {
+			t.Errorf("Expecting move %d to be %s, got %s", i, moves[i], move)
+		}
+	}
+}
+
+func Test_PieceList(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces := unit.PieceList()
+	if len(pieces) != 32 {
+		t.Fatalf("Expecting 32 pieces, got %d", len(pieces))
+	}
+	if pieces[0].Piece != WhiteRook || pieces[0].Pos != A1 {
+		t.Errorf("Expecting a white rook on a1, got %s on %s", pieces[0].Piece, pieces[0].Pos)
+	}
+	if pieces[31].Piece != BlackRook || pieces[31].Pos != H8 {
+		t.Errorf("Expecting a black rook on h8, got %s on %s", pieces[31].Piece, pieces[31].Pos)
+	}
+}
+
+func Test_ValidMoves_pawn_opening_jumps(t *testing.T) {
+	hasMove := func(game *Game, move string) bool {
+		for _, m := range game.ValidMoves() {
+			if m.String() == move {
+				return true
+			}
+		}
+		return false
+	}
+	cases := []struct {
+		fen    string
+		single string
+		jump   string
+		valid  []bool
+	}{
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", "e2e3", "e2e4", []bool{true, true}},
+		{"4k3/8/8/8/4n3/8/4P3/4K3 w - - 0 1", "e2e3", "e2e4", []bool{true, false}},
+		{"4k3/8/8/8/8/4n3/4P3/4K3 w - - 0 1", "e2e3", "e2e4", []bool{false, false}},
+		{"4k3/4p3/8/8/8/8/8/4K3 b - - 0 1", "e7e6", "e7e5", []bool{true, true}},
+		{"4k3/4p3/8/4N3/8/8/8/4K3 b - - 0 1", "e7e6", "e7e5", []bool{true, false}},
+		{"4k3/4p3/4N3/8/8/8/8/4K3 b - - 0 1", "e7e6", "e7e5", []bool{false, false}},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasMove(unit, c.single) != c.valid[0] {
+			t.Errorf("Expecting %s to be valid (%v) in %s", c.single, c.valid[0], c.fen)
+		}
+		if hasMove(unit, c.jump) != c.valid[1] {
+			t.Errorf("Expecting %s to be valid (%v) in %s", c.jump, c.valid[1], c.fen)
+		}
+	}
+}
+
+func Test_ApplyMove_blocking_pawn_opening_jump(t *testing.T) {
+	unit, err := ParseFEN("7k/4p3/8/8/8/8/4P3/K5N1 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasMove := func(game *Game, move string) bool {
+		for _, m := range game.ValidMoves() {
+			if m.String() == move {
+				return true
+			}
+		}
+		return false
+	}
+	play := func(game *Game, moves ...string) *Game {
+		for _, move := range moves {
+			game = game.ApplyMove(MustParseMove(move))
+		}
+		return game
+	}
+
+	// The knight blocks the square in front of the black pawn
+	game := play(unit, "g1f3", "h8g8", "f3g5", "g8h8", "g5e6")
+	if hasMove(game, "e7e6") || hasMove(game, "e7e5") {
+		t.Errorf("Expecting the black pawn to be blocked, got %v", game.ValidMoves())
+	}
+	// And then moves out of the way again
+	game = play(game, "h8g8", "e6c5")
+	if !hasMove(game, "e7e6") || !hasMove(game, "e7e5") {
+		t.Errorf("Expecting the black pawn to be able to move again, got %v", game.ValidMoves())
+	}
+
+	// The knight blocks the square the white pawn wants to jump to
+	game = play(unit, "g1f3", "h8g8", "f3g5", "g8h8", "g5e4", "h8g8")
+	if !hasMove(game, "e2e3") || hasMove(game, "e2e4") {
+		t.Errorf("Expecting the white pawn to be able to push but not jump, got %v", game.ValidMoves())
+	}
+	game = play(game, "e4c3", "g8h8")
+	if !hasMove(game, "e2e3") || !hasMove(game, "e2e4") {
+		t.Errorf("Expecting the white pawn to be unblocked, got %v", game.ValidMoves())
+	}
+	// The knight blocks the square in front of the white pawn
+	game = play(game, "c3d5", "h8g8", "d5e3", "g8h8")
+	if hasMove(game, "e2e3") || hasMove(game, "e2e4") {
+		t.Errorf("Expecting the white pawn to be blocked, got %v", game.ValidMoves())
+	}
+}
+
+func Test_WithSideToMove(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	passed := unit.WithSideToMove(Black)
+	expected := "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 3"
+	if passed.FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, passed.FENString())
+	}
+	for _, move := range passed.ValidMoves() {
+		if passed.Board[move.From].Color() != Black {
+			t.Errorf("Expecting only moves for black, got %s", move)
+		}
+	}
+	back := passed.WithSideToMove(White)
+	if back.ToMove != White || back.EnPassantVulnerable != NoPosition {
+		t.Errorf("Expecting white to move without en passant, got %s", back.FENString())
+	}
+	for _, move := range back.ValidMoves() {
+		if move.String() == "e5f6" {
+			t.Errorf("Expecting the en passant capture to be forfeited")
+		}
+	}
+	if len(back.ValidMoves()) != len(unit.ValidMoves())-1 {
+		t.Errorf("Expecting all but the en passant capture, got %v", back.ValidMoves())
+	}
+	if unit.EnPassantVulnerable != F6 || len(unit.ValidMoves()) != 31 {
+		t.Errorf("Expecting the original game to be unchanged, got %s", unit.FENString())
+	}
+}
+
+func Test_ParseFEN_rejects_adjacent_kings(t *testing.T) {
+	if _, err := ParseFEN("8/8/8/4k3/4K3/8/8/8 w - - 0 1"); err == nil {
+		t.Errorf("Expecting an error for kings on e4 and e5")
+	}
+	if _, err := ParseFEN("8/8/8/3k4/4K3/8/8/8 b - - 0 1"); err == nil {
+		t.Errorf("Expecting an error for kings on e4 and d5")
+	}
+	if _, err := ParseFEN("8/8/4k3/8/4K3/8/8/8 w - - 0 1"); err != nil {
+		t.Errorf("Expecting kings two squares apart to be valid, got %s", err)
+	}
+}
+
+func Test_ParseFEN_reports_the_invalid_field(t *testing.T) {
+	cases := map[string]FENField{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1":    FENColor,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e9 0 1":   FENEnPassant,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - zero 1": FENClock,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 one":  FENClock,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQxq - 0 1":    FENCastle,
+		"rnbqkbnr/ppppxppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1":    FENBoard,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR/8 w KQkq - 0 1":  FENBoard,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNRR w KQkq - 0 1":   FENBoard,
+		"8/8/8/4k3/4K3/8/8/8 w - - 0 1":                               FENBoard,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -":        FENClock,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w":               FENCastle,
+	}
+	for fenStr, expected := range cases {
+		_, err := ParseFEN(fenStr)
+		fenErr, ok := err.(*FENError)
+		if !ok {
+			t.Errorf("Expecting a FENError for %s, got %v", fenStr, err)
+		} else if fenErr.Field != expected {
+			t.Errorf("Expecting an error in the %s field for %s, got %s", expected, fenStr, fenErr)
+		}
+	}
+}
+
+func Test_CastlingPathClear(t *testing.T) {
+	unit, err := ParseFEN("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, color := range Colors {
+		for _, side := range []CastleStatus{Kingside, Queenside} {
+			if !unit.CastlingPathClear(color, side) {
+				t.Errorf("Expecting the castling path to be clear for %s on side %d", color, side)
+			}
+		}
+		if unit.CastlingPathClear(color, None) || unit.CastlingPathClear(color, Both) {
+			t.Errorf("Expecting only a single side to have a castling path")
+		}
+	}
+	cases := []struct {
+		fen   string
+		color Color
+		side  CastleStatus
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3KB1R w KQkq - 0 1", White, Kingside},
+		{"r3k2r/8/8/8/8/8/8/R3K1NR w KQkq - 0 1", White, Kingside},
+		{"r3k2r/8/8/8/8/8/8/RN2K2R w KQkq - 0 1", White, Queenside},
+		{"r3k2r/8/8/8/8/8/8/R1B1K2R w KQkq - 0 1", White, Queenside},
+		{"r3k2r/8/8/8/8/8/8/R2QK2R w KQkq - 0 1", White, Queenside},
+		{"r3kb1r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", Black, Kingside},
+		{"r3k1nr/8/8/8/8/8/8/R3K2R b KQkq - 0 1", Black, Kingside},
+		{"rn2k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", Black, Queenside},
+		{"r1b1k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", Black, Queenside},
+		{"r2qk2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", Black, Queenside},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.CastlingPathClear(c.color, c.side) {
+			t.Errorf("Expecting the castling path to be blocked in %s", c.fen)
+		}
+		other := Kingside
+		if c.side == Kingside {
+			other = Queenside
+		}
+		if !unit.CastlingPathClear(c.color, other) {
+			t.Errorf("Expecting the castling path on the other side to be clear in %s", c.fen)
+		}
+	}
+}
+
+func Test_CastlingPathSafe(t *testing.T) {
+	cases := []struct {
+		fen      string
+		color    Color
+		side     CastleStatus
+		expected bool
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", White, Kingside, true},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", White, Queenside, true},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", Black, Kingside, true},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", Black, Queenside, true},
+		// Transit squares
+		{"r3kr2/8/8/8/8/8/8/R3K2R w KQq - 0 1", White, Kingside, false},
+		{"r2rk2r/8/8/8/8/8/8/R3K2R w KQk - 0 1", White, Queenside, false},
+		{"r3k2r/8/8/8/8/8/8/R3KR2 b Qkq - 0 1", Black, Kingside, false},
+		{"r3k2r/8/8/8/8/8/8/R2RK2R b Kkq - 0 1", Black, Queenside, false},
+		// Destination squares
+		{"r3k1r1/8/8/8/8/8/8/R3K2R w KQq - 0 1", White, Kingside, false},
+		{"r1r1k2r/8/8/8/8/8/8/R3K2R w KQk - 0 1", White, Queenside, false},
+		// The rook can pass through an attacked square
+		{"rr2k2r/8/8/8/8/8/8/R3K2R w KQk - 0 1", White, Queenside, true},
+		{"r3k2r/8/8/8/8/8/8/RR2K2R b Kkq - 0 1", Black, Queenside, true},
+		// The king is in check
+		{"r3k2r/8/8/8/8/8/4r3/R3K2R w KQk - 0 1", White, Kingside, false},
+		{"r3k2r/8/8/8/8/8/4r3/R3K2R w KQk - 0 1", White, Queenside, false},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := unit.CastlingPathSafe(c.color, c.side); got != c.expected {
+			t.Errorf("Expecting castling to be safe (%v) in %s, got %v", c.expected, c.fen, got)
+		}
+		castles := NewMove(E1, G1)
+		if c.side == Queenside {
+			castles = NewMove(E1, C1)
+		}
+		if c.color == Black {
+			castles = NewMove(castles.From+56, castles.To+56)
+		}
+		found := false
+		for _, move := range unit.ValidMoves() {
+			if move.String() == castles.String() {
+				found = true
+			}
+		}
+		if found != c.expected {
+			t.Errorf("Expecting castles %s to be valid (%v) in %s, got %v", castles, c.expected, c.fen, found)
+		}
+	}
+}
+
+func Test_ValidMoves_castling_respects_castle_status(t *testing.T) {
+	cases := map[string][]string{
+		"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1": []string{"e1c1", "e1g1"},
+		"r3k2r/8/8/8/8/8/8/R3K2R w Kkq - 0 1":  []string{"e1g1"},
+		"r3k2r/8/8/8/8/8/8/R3K2R w Qkq - 0 1":  []string{"e1c1"},
+		"r3k2r/8/8/8/8/8/8/R3K2R w kq - 0 1":   []string{},
+		"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1": []string{"e8c8", "e8g8"},
+		"r3k2r/8/8/8/8/8/8/R3K2R b KQk - 0 1":  []string{"e8g8"},
+		"r3k2r/8/8/8/8/8/8/R3K2R b KQq - 0 1":  []string{"e8c8"},
+		"r3k2r/8/8/8/8/8/8/R3K2R b KQ - 0 1":   []string{},
+	}
+	castles := map[string]bool{"e1g1": true, "e1c1": true, "e8g8": true, "e8c8": true}
+	for fenStr, expected := range cases {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := []string{}
+		for _, move := range unit.ValidMoves() {
+			if castles[move.String()] {
+				found = append(found, move.String())
+			}
+		}
+		if len(found) != len(expected) {
+			t.Errorf("Expecting castles %v in %s, got %v", expected, fenStr, found)
+			continue
+		}
+		for i, move := range expected {
+			if found[i] != move {
+				t.Errorf("Expecting castles %v in %s, got %v", expected, fenStr, found)
+				break
+			}
+		}
+	}
+}
+
+func Test_IsLegalMove(t *testing.T) {
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R b KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R2Pp1k/8/6P1/8 b - e3 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"4k3/8/8/8/8/8/4q3/R3K2R w KQ - 0 1",
+	}
+	random := rand.New(rand.NewSource(1))
+	promotions := []Piece{NoPiece, NoPiece, WhiteQueen, BlackKnight, WhitePawn, BlackKing}
+	for _, fenStr := range fens {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legal := map[string]bool{}
+		for _, move := range unit.ValidMoves() {
+			legal[move.String()] = true
+			if !unit.IsLegalMove(move) {
+				t.Errorf("Expecting %s to be legal in %s", move, fenStr)
+			}
+		}
+		own := unit.Pieces.GetAllPositionsForColor(unit.ToMove)
+		for i := 0; i < 100; i++ {
+			from := Position(random.Intn(64))
+			if i%2 == 0 {
+				// Most random moves are nonsense, so also try moves
+				// with pieces that are actually ours.
+				from = own[random.Intn(len(own))]
+			}
+			to := Position(random.Intn(64))
+			if reachable := unit.validMoves[from].ToPositions(); i%4 == 0 && len(reachable) > 0 {
+				// Pseudo-legal moves that might leave the king in check
+				to = reachable[random.Intn(len(reachable))]
+			}
+			move := &Move{
+				From:    from,
+				To:      to,
+				Promote: promotions[random.Intn(len(promotions))],
+			}
+			isLegal := legal[move.String()]
+			if unit.IsLegalMove(move) != isLegal {
+				t.Errorf("Expecting legality of %s (%v) to be %v in %s", move, move.Promote, isLegal, fenStr)
+			}
+		}
+	}
+}
+
+func Test_EnPassantCaptureSquare(t *testing.T) {
+	cases := []struct {
+		fen      string
+		capture  string
+		expected Position
+	}{
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "e5f6", F5},
+		{"rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", "d4e3", E4},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.EnPassantCaptureSquare() != c.expected {
+			t.Errorf("Expecting capture square %s in %s, got %s", c.expected, c.fen, unit.EnPassantCaptureSquare())
+		}
+		next := unit.ApplyMove(MustParseMove(c.capture))
+		if next.Board[c.expected] != NoPiece {
+			t.Errorf("Expecting the pawn on %s to be captured by %s", c.expected, c.capture)
+		}
+		if next.EnPassantCaptureSquare() != NoPosition {
+			t.Errorf("Expecting no capture square after %s, got %s", c.capture, next.EnPassantCaptureSquare())
+		}
+	}
+}
+
+func Test_AttacksFrom(t *testing.T) {
+	unit, err := ParseFEN("4k3/4p3/8/8/8/4P3/8/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		piece    Piece
+		pos      Position
+		expected []Position
+	}{
+		// The e-file is blocked by the pawns on both sides
+		{WhiteRook, E5, []Position{E6, E7, E4, E3, A5, B5, C5, D5, F5, G5, H5}},
+		{BlackRook, E5, []Position{E6, E7, E4, E3, A5, B5, C5, D5, F5, G5, H5}},
+		// The king on e1 is hidden behind the pawn on e3
+		{WhiteQueen, E2, []Position{E3, E1, A2, B2, C2, D2, F2, G2, H2, D1, F1, D3, C4, B5, A6, F3, G4, H5}},
+		// The knight on an edge can only go one way
+		{WhiteKnight, A4, []Position{B6, C5, C3, B2}},
+		{BlackKnight, H1, []Position{G3, F2}},
+		// Pawns only attack diagonally
+		{WhitePawn, D6, []Position{C7, E7}},
+		{BlackPawn, A3, []Position{B2}},
+	}
+	for _, c := range cases {
+		attacks := unit.AttacksFrom(c.piece, c.pos)
+		if len(attacks) != len(c.expected) {
+			t.Errorf("Expecting %s on %s to attack %v, got %v", c.piece, c.pos, c.expected, attacks)
+			continue
+		}
+		for _, e := range c.expected {
+			found := false
+			for _, a := range attacks {
+				if a == e {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expecting %s on %s to attack %s, got %v", c.piece, c.pos, e, attacks)
+			}
+		}
+	}
+}
+
+func Test_ValidMoves_sliders_have_no_duplicates(t *testing.T) {
+	cases := []struct {
+		fen      string
+		expected int
+		captures []string
+	}{
+		{"4k3/8/8/8/3Q4/8/8/4K3 w - - 0 1", 27, nil},
+		{"4k3/3p2p1/8/8/3Q4/8/3P4/4K3 w - - 0 1", 23, []string{"d4d7", "d4g7"}},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := map[string]bool{}
+		for _, move := range unit.ValidMovesForPiece(D4) {
+			if seen[move.String()] {
+				t.Errorf("Expecting %s to only be generated once in %s", move, c.fen)
+			}
+			seen[move.String()] = true
+		}
+		if len(seen) != c.expected {
+			t.Errorf("Expecting %d queen moves in %s, got %d", c.expected, c.fen, len(seen))
+		}
+		for _, capture := range c.captures {
+			if !seen[capture] {
+				t.Errorf("Expecting capture %s in %s", capture, c.fen)
+			}
+		}
+	}
+}
+
+func Test_IsSquareDefended(t *testing.T) {
+	unit, err := ParseFEN("4k3/8/8/3n4/8/2N5/1P6/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		sq       Position
+		by       Color
+		expected bool
+	}{
+		// The pawn on b2 defends the knight on c3, which is attacked by
+		// the knight on d5
+		{C3, White, true},
+		{C3, Black, true},
+		// The knight on d5 is attacked, but not defended
+		{D5, White, true},
+		{D5, Black, false},
+		// Nothing defends the pawn
+		{B2, White, false},
+		{B2, Black, false},
+		{E3, Black, true},
+	}
+	for _, c := range cases {
+		if unit.IsSquareDefended(c.sq, c.by) != c.expected {
+			t.Errorf("Expecting IsSquareDefended(%s, %s) to be %v", c.sq, c.by, c.expected)
+		}
+	}
+	// The black king on e1 blocks the rook's line to the knight on g1
+	unit, err = ParseFEN("8/8/8/8/7K/8/8/R3k1n1 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.IsSquareDefended(G1, White) {
+		t.Errorf("Expecting the rook not to reach g1 through the king")
+	}
+	if !unit.IsSquareDefended(D1, White) {
+		t.Errorf("Expecting the rook to reach d1")
+	}
+}
+
+func Test_LastMove_and_LastCapturedPiece(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.LastMove() != nil {
+		t.Errorf("Expecting no last move, got %s", unit.LastMove())
+	}
+	if unit.LastCapturedPiece() != NoPiece {
+		t.Errorf("Expecting no captured piece, got %s", unit.LastCapturedPiece())
+	}
+	capture := unit.ApplyMove(MustParseMove("e4d5"))
+	if capture.LastMove().String() != "e4d5" {
+		t.Errorf("Expecting last move e4d5, got %s", capture.LastMove())
+	}
+	if capture.LastCapturedPiece() != BlackPawn {
+		t.Errorf("Expecting a black pawn to be captured, got %s", capture.LastCapturedPiece())
+	}
+	quiet := capture.ApplyMove(MustParseMove("g8f6"))
+	if quiet.LastMove().String() != "g8f6" {
+		t.Errorf("Expecting last move g8f6, got %s", quiet.LastMove())
+	}
+	if quiet.LastCapturedPiece() != NoPiece {
+		t.Errorf("Expecting no captured piece after a quiet move, got %s", quiet.LastCapturedPiece())
+	}
+
+	enpassant, err := ParseFEN("rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enpassant.ApplyMove(MustParseMove("e5f6")).LastCapturedPiece() != BlackPawn {
+		t.Errorf("Expecting a black pawn to be captured en passant")
+	}
+}
+
+func Test_InCheck_pawns_only_attack_diagonally(t *testing.T) {
+	cases := []struct {
+		fen      string
+		move     string
+		expected bool
+	}{
+		{"8/8/4k3/8/4P3/8/8/4K3 w - - 0 1", "e4e5", false},
+		{"8/8/4k3/8/3P4/8/8/4K3 w - - 0 1", "d4d5", true},
+		{"4k3/8/8/4p3/8/4K3/8/8 b - - 0 1", "e5e4", false},
+		{"4k3/8/8/5p2/8/4K3/8/8 b - - 0 1", "f5f4", true},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := unit.ApplyMove(MustParseMove(c.move))
+		if next.InCheck() != c.expected {
+			t.Errorf("Expecting InCheck to be %v after %s in %s", c.expected, c.move, c.fen)
+		}
+		kingPos := next.Pieces.GetKingPos(next.ToMove)
+		if next.SquareControl.AttacksSquare(next.ToMove.Opposite(), kingPos) != c.expected {
+			t.Errorf("Expecting AttacksSquare on the king to be %v after %s in %s", c.expected, c.move, c.fen)
+		}
+	}
+}
+
+func Test_MakeNullMove(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	null, enpassant := unit.MakeNullMove()
+	expected := "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR b KQkq - 1 3"
+	if null.FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, null.FENString())
+	}
+	if enpassant != F6 {
+		t.Errorf("Expecting the forfeited en passant square f6, got %s", enpassant)
+	}
+	if unit.EnPassantVulnerable != F6 || unit.ToMove != White || unit.HalfmoveClock != 0 {
+		t.Errorf("Expecting the original game to be unchanged, got %s", unit.FENString())
+	}
+	back := null.UnmakeNullMove(enpassant)
+	if !back.Equal(unit) {
+		t.Errorf("Expecting %s after taking back the null move, got %s", unit.FENString(), back.FENString())
+	}
+	if len(back.ValidMoves()) != len(unit.ValidMoves()) {
+		t.Errorf("Expecting the en passant capture to be restored, got %v", back.ValidMoves())
+	}
+	flipped := unit.WithSideToMove(Black).WithSideToMove(White)
+	if !back.WithSideToMove(White).Equal(flipped) {
+		t.Errorf("Expecting %s, got %s", flipped.FENString(), back.WithSideToMove(White).FENString())
+	}
+
+	blackNull, _ := null.MakeNullMove()
+	expected = "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq - 2 4"
+	if blackNull.FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, blackNull.FENString())
+	}
+	if !blackNull.UnmakeNullMove(NoPosition).Equal(null) {
+		t.Errorf("Expecting %s, got %s", null.FENString(), blackNull.UnmakeNullMove(NoPosition).FENString())
+	}
+}
+
+func Test_IsPassedPawn(t *testing.T) {
+	unit, err := ParseFEN("4k3/p7/8/1P5p/8/4P3/4p3/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[Position]bool{
+		B5: false,
+		E3: true,
+		A7: false,
+		H5: true,
+		E2: true,
+		E1: false,
+	}
+	for pos, expected := range cases {
+		if unit.IsPassedPawn(pos) != expected {
+			t.Errorf("Expecting IsPassedPawn(%s) to be %v", pos, expected)
+		}
+	}
+}
+
+func Test_SeenKey_ignores_en_passant_squares_that_cant_be_captured(t *testing.T) {
+	withEnPassant, err := ParseFEN("4k3/8/8/8/4P3/8/8/4K3 b - e3 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	without, err := ParseFEN("4k3/8/8/8/4P3/8/8/4K3 b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withEnPassant.SeenKey() != without.SeenKey() {
+		t.Errorf("Expecting %s and %s to have the same key", withEnPassant.SeenKey(), without.SeenKey())
+	}
+	seen := NewSeenMap()
+	seen.Set(withEnPassant)
+	if !seen.Seen(without) || len(seen) != 1 {
+		t.Errorf("Expecting the positions to collapse into one search node")
+	}
+
+	capturable, err := ParseFEN("4k3/8/8/8/3pP3/8/8/4K3 b - e3 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Fields(capturable.SeenKey())[3] != "e3" {
+		t.Errorf("Expecting the en passant square to be kept, got %s", capturable.SeenKey())
+	}
+}
+
+func Test_SeenKey_only_ignores_the_halfmove_clock_far_from_the_fifty_move_rule(t *testing.T) {
+	seen := func(clock int) *Game {
+		game, err := ParseFEN(fmt.Sprintf("k7/8/8/8/8/8/8/4K1Q1 w - - %d 80", clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return game
+	}
+	cases := []struct {
+		clock, otherClock int
+		same              bool
+	}{
+		{0, 10, true},
+		{0, SeenKeyMaxHalfmoveClock - 1, true},
+		{0, SeenKeyMaxHalfmoveClock, false},
+		{SeenKeyMaxHalfmoveClock, 60, false},
+		{98, 99, false},
+		{99, 99, true},
+	}
+	for _, c := range cases {
+		seenMap := NewSeenMap()
+		seenMap.Set(seen(c.clock))
+		if seenMap.Seen(seen(c.otherClock)) != c.same {
+			t.Errorf("Expecting clocks %d and %d to be the same search node: %v", c.clock, c.otherClock, c.same)
+		}
+	}
+}
+
+func Test_IsLegalMove_promotions(t *testing.T) {
+	unit, err := ParseFEN("8/4P1N1/8/8/8/8/k7/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		move     *Move
+		expected bool
+	}{
+		{NewPromotionMove(E7, E8, WhiteQueen), true},
+		{NewPromotionMove(E7, E8, WhiteKnight), true},
+		{NewMove(E7, E8), false},
+		{NewPromotionMove(E7, E8, WhiteKing), false},
+		{NewPromotionMove(E7, E8, WhitePawn), false},
+		{NewPromotionMove(E7, E8, BlackQueen), false},
+		{NewMove(G7, E8), true},
+		{NewPromotionMove(G7, E8, WhiteQueen), false},
+		{NewPromotionMove(E1, E2, WhiteQueen), false},
+	}
+	for _, c := range cases {
+		if unit.IsLegalMove(c.move) != c.expected {
+			t.Errorf("Expecting IsLegalMove(%s) to be %v", c.move, c.expected)
+		}
+	}
+}
+
+func Test_ApplyMove_halfmove_clock(t *testing.T) {
+	unit, err := ParseFEN("r3k3/1p6/8/8/8/8/4P3/R3K1N1 w - - 10 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := []struct {
+		move     string
+		expected int
+	}{
+		{"g1f3", 11}, // quiet move
+		{"e8d8", 12}, // quiet king move
+		{"a1a8", 0},  // capture
+		{"d8c7", 1},
+		{"e2e4", 0}, // pawn move
+		{"b7b5", 0},
+		{"f3d4", 1},
+		{"b5b4", 0},
+		{"a8a4", 1},
+		{"c7b6", 2},
+		{"a4b4", 0}, // capture by the rook
+		{"b6c5", 1},
+	}
+	for _, m := range moves {
+		if !unit.IsLegalMove(MustParseMove(m.move)) {
+			t.Fatalf("Expecting %s to be legal in %s", m.move, unit.FENString())
+		}
+		unit = unit.ApplyMove(MustParseMove(m.move))
+		if unit.HalfmoveClock != m.expected {
+			t.Errorf("Expecting the halfmove clock to be %d after %s, got %d", m.expected, m.move, unit.HalfmoveClock)
+		}
+	}
+}
+
+func Test_ApplyMove_en_passant_resets_the_halfmove_clock(t *testing.T) {
+	cases := []struct {
+		fen     string
+		capture string
+	}{
+		{"4k3/8/8/3pP3/8/8/8/4K1N1 w - d6 7 20", "e5d6"},
+		{"4k1n1/8/8/8/3pP3/8/8/4K3 b - e3 7 20", "d4e3"},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := unit.ApplyMove(MustParseMove(c.capture))
+		if next.HalfmoveClock != 0 {
+			t.Errorf("Expecting the halfmove clock to be reset by %s, got %d", c.capture, next.HalfmoveClock)
+		}
+		if next.LastCapturedPiece() == NoPiece {
+			t.Errorf("Expecting %s to be a capture", c.capture)
+		}
+	}
+}
+
+func Test_ValidCaptures_and_ValidQuietMoves(t *testing.T) {
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"4k3/8/8/8/8/8/8/4K2r w - - 0 1",
+	}
+	for _, fen := range fens {
+		unit, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := map[string]bool{}
+		for _, move := range unit.ValidCaptures() {
+			if !unit.IsCapture(move) {
+				t.Errorf("Expecting %s to be a capture in %s", move, fen)
+			}
+			seen[move.String()] = true
+		}
+		for _, move := range unit.ValidQuietMoves() {
+			if seen[move.String()] {
+				t.Errorf("Expecting %s to not be both a capture and a quiet move in %s", move, fen)
+			}
+			seen[move.String()] = true
+		}
+		if len(seen) != len(unit.ValidMoves()) {
+			t.Errorf("Expecting the captures and quiet moves to add up to %d valid moves in %s, got %d", len(unit.ValidMoves()), fen, len(seen))
+		}
+		for _, move := range unit.ValidMoves() {
+			if !seen[move.String()] {
+				t.Errorf("Expecting %s to be a capture or a quiet move in %s", move, fen)
+			}
+		}
+	}
+	unit, err := ParseFEN(fens[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !unit.IsCapture(MustParseMove("e5f6")) {
+		t.Errorf("Expecting the en passant capture to be a capture")
+	}
+}
+
+func Test_CountValidMoves(t *testing.T) {
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"8/8/8/8/k2Pp2Q/8/8/3K4 b - d3 0 1",
+	}
+	random := rand.New(rand.NewSource(1))
+	for _, fen := range fens {
+		unit, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Play random games from each position to cover pins, checks,
+		// promotions and en passant.
+		for i := 0; i < 40 && !unit.IsFinished(); i++ {
+			count := unit.CountValidMoves()
+			if count != len(unit.ValidMoves()) {
+				t.Errorf("Expecting %d valid moves in %s, got %d", len(unit.ValidMoves()), unit.FENString(), count)
+			}
+			moves := unit.ValidMoves()
+			unit = unit.ApplyMove(moves[random.Intn(len(moves))])
+		}
+	}
+}
+
+func Test_ValidCaptures_matches_ValidMoves(t *testing.T) {
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"8/8/8/8/k2Pp2Q/8/8/3K4 b - d3 0 1",
+		// The pinned rook can only take the pinning bishop
+		"4k3/8/8/8/1b6/2R5/3n4/4K3 w - - 0 1",
+		// The king can't take the defended knight
+		"4k3/8/8/8/8/5b2/4n3/4K3 w - - 0 1",
+	}
+	random := rand.New(rand.NewSource(1))
+	for _, fen := range fens {
+		unit, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 40 && !unit.IsFinished(); i++ {
+			// Parse the position again, so that the valid moves aren't
+			// cached and we get the fast path.
+			fresh, err := ParseFEN(unit.FENString())
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := map[string]bool{}
+			for _, move := range unit.ValidMoves() {
+				if unit.IsCapture(move) {
+					expected[move.String()] = true
+				}
+			}
+			captures := fresh.ValidCaptures()
+			if len(captures) != len(expected) {
+				t.Errorf("Expecting captures %v in %s, got %v", expected, unit.FENString(), captures)
+			}
+			for _, move := range captures {
+				if !expected[move.String()] {
+					t.Errorf("Expecting %s not to be a valid capture in %s", move, unit.FENString())
+				}
+			}
+			moves := unit.ValidMoves()
+			unit = unit.ApplyMove(moves[random.Intn(len(moves))])
+		}
+	}
+}
+
+func Benchmark_ValidMoves(b *testing.B) {
+	unit, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		unit.valid = nil
+		unit.ValidMoves()
+	}
+}
+
+func Benchmark_CountValidMoves(b *testing.B) {
+	unit, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		unit.CountValidMoves()
+	}
+}
+
+func Test_ApplyMove_knights_reach_the_vacated_square(t *testing.T) {
+	unit, err := ParseFEN("4k3/8/5n2/8/4P3/8/3N4/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.validMoves[D2].IsSet(E4) {
+		t.Errorf("Expecting the knight on d2 to be blocked by its own pawn on e4")
+	}
+	next := unit.ApplyMove(MustParseMove("e4e5"))
+	for _, knight := range []Position{D2, F6} {
+		if !next.validMoves[knight].IsSet(E4) {
+			t.Errorf("Expecting the knight on %s to reach e4 after the pawn moved away", knight)
+		}
+	}
+	expected := NewValidMovesListFromBoard(next.Board)
+	for _, knight := range []Position{D2, F6} {
+		if next.validMoves[knight] != expected[knight] {
+			t.Errorf("Expecting the knight on %s to go to %v, got %v", knight, expected[knight].ToPositions(), next.validMoves[knight].ToPositions())
+		}
+	}
+	found := false
+	for _, move := range next.ValidMoves() {
+		if move.String() == "f6e4" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expecting f6e4 to be a valid move, got %v", next.ValidMoves())
+	}
+}
+
+func Test_ThreatenedPieces(t *testing.T) {
+	cases := []struct {
+		fen      string
+		color    Color
+		expected []Position
+	}{
+		// The knight on e5 is hanging, the one on c3 is defended by a pawn
+		{"4k3/4r3/8/4N3/2r5/2N5/1P6/4K3 w - - 0 1", White, []Position{E5}},
+		// The defended knight on d5 is attacked by a pawn
+		{"4k3/8/4p3/3N4/8/8/8/3RK3 w - - 0 1", White, []Position{D5}},
+		// The black king can't take the defended rook
+		{"8/8/8/8/8/8/3kR3/4R2K b - - 0 1", White, []Position{}},
+		{"8/8/8/8/8/8/3kR3/7K b - - 0 1", White, []Position{E2}},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", Black, []Position{}},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		threatened := unit.ThreatenedPieces(c.color)
+		if len(threatened) != len(c.expected) {
+			t.Errorf("Expecting %v to be threatened in %s, got %v", c.expected, c.fen, threatened)
+			continue
+		}
+		for i, pos := range c.expected {
+			if threatened[i] != pos {
+				t.Errorf("Expecting %v to be threatened in %s, got %v", c.expected, c.fen, threatened)
+			}
+		}
+	}
+}
+
+func Test_DeliveredMate(t *testing.T) {
+	cases := []struct {
+		fen      string
+		move     string
+		expected bool
+	}{
+		{"7k/8/6K1/8/8/8/8/Q7 w - - 0 1", "a1a8", true},
+		{"7k/8/6K1/8/8/8/8/Q7 w - - 0 1", "a1h1", false},
+		{"7k/8/6K1/8/8/8/8/Q7 w - - 0 1", "a1b2", false},
+		{"rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2", "d8h4", true},
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "a1a8", true},
+		{"6k1/5pp1/8/8/8/8/8/R5K1 w - - 0 1", "a1a8", false},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := unit.ApplyMove(MustParseMove(c.move))
+		if next.DeliveredMate() != c.expected {
+			t.Errorf("Expecting DeliveredMate to be %v after %s in %s", c.expected, c.move, c.fen)
+		}
+	}
+	random := rand.New(rand.NewSource(1))
+	for _, fen := range []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	} {
+		unit, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 20; i++ {
+			for _, move := range unit.ValidMoves() {
+				next := unit.ApplyMove(move)
+				inCheck := next.InCheck()
+				next.inCheck = nil
+				if inCheck != next.InCheck() {
+					t.Errorf("Expecting ApplyMove to work out the check after %s in %s", move, unit.FENString())
+				}
+			}
+			moves := unit.ValidMoves()
+			if len(moves) == 0 {
+				break
+			}
+			unit = unit.ApplyMove(moves[random.Intn(len(moves))])
+		}
+	}
+}
+
+func Benchmark_DeliveredMate(b *testing.B) {
+	unit, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		b.Fatal(err)
+	}
+	moves := unit.ValidMoves()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, move := range moves {
+			unit.ApplyMove(move).DeliveredMate()
+		}
+	}
+}
+
+func Test_MovesThatGiveCheck(t *testing.T) {
+	unit, err := ParseFEN("4k3/8/8/1N1p4/4P3/8/8/4R2K w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// exd5 is a discovered check by the rook, the knight checks directly
+	expected := map[string]bool{"e4d5": true, "b5d6": true, "b5c7": true}
+	checks := unit.MovesThatGiveCheck()
+	if len(checks) != len(expected) {
+		t.Errorf("Expecting %d moves that give check, got %v", len(expected), checks)
+	}
+	for _, move := range checks {
+		if !expected[move.String()] {
+			t.Errorf("Expecting %s not to give check", move)
+		}
+	}
+	for _, move := range unit.ValidMoves() {
+		if unit.GivesCheck(move) != expected[move.String()] {
+			t.Errorf("Expecting GivesCheck to be %v for %s", expected[move.String()], move)
+		}
+	}
+}
+
+func Test_SetCastlingFromKingRookPositions(t *testing.T) {
+	cases := map[string]string{
+		"r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1":  "KQkq",
+		"r3k2r/8/8/8/8/8/8/R3K1R1 w - - 0 1": "Qkq",
+		"1r2k2r/8/8/8/8/8/8/R3K3 w - - 0 1":  "Qk",
+		"r2k3r/8/8/8/8/8/8/4K2R w - - 0 1":   "K",
+		"4k3/8/8/8/8/8/8/4K3 w - - 0 1":      "-",
+	}
+	for fenStr, expected := range cases {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit.SetCastlingFromKingRookPositions()
+		if unit.CastleStatuses.String() != expected {
+			t.Errorf("Expecting castling rights %s for %s, got %s", expected, fenStr, unit.CastleStatuses.String())
+		}
+	}
+	unit, err := ParseFEN("r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit.SetCastlingFromKingRookPositions()
+	if unit.CastleStatuses.White != Both {
+		t.Errorf("Expecting white to be able to castle on both sides, got %v", unit.CastleStatuses.White)
+	}
+	if !unit.IsLegalMove(MustParseMove("e1g1")) || !unit.IsLegalMove(MustParseMove("e1c1")) {
+		t.Errorf("Expecting castling to be legal in %s", unit.FENString())
+	}
+}
+
+func Test_ApplyMove_castles_moves_the_rook(t *testing.T) {
+	cases := []struct {
+		fen      string
+		move     string
+		rookFrom Position
+		rookTo   Position
+		expected string
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", H1, F1, "r3k2r/8/8/8/8/8/8/R4RK1 b kq - 1 1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1c1", A1, D1, "r3k2r/8/8/8/8/8/8/2KR3R b kq - 1 1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8g8", H8, F8, "r4rk1/8/8/8/8/8/8/R3K2R w KQ - 1 2"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", A8, D8, "2kr3r/8/8/8/8/8/8/R3K2R w KQ - 1 2"},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rook := unit.Board[c.rookFrom]
+		result := unit.ApplyMove(MustParseMove(c.move))
+		if result.Board[c.rookTo] != rook || result.Board[c.rookFrom] != NoPiece {
+			t.Errorf("Expecting the rook to move from %s to %s after %s", c.rookFrom, c.rookTo, c.move)
+		}
+		rooks := result.Pieces[rook.Color()][Rook]
+		if !rooks.IsSet(c.rookTo) || rooks.IsSet(c.rookFrom) {
+			t.Errorf("Expecting the rook's piece positions to be updated after %s, got %v", c.move, rooks.ToPositions())
+		}
+		if result.FENString() != c.expected {
+			t.Errorf("Expecting %s after %s, got %s", c.expected, c.move, result.FENString())
+		}
+		parsed, err := ParseFEN(result.FENString())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.FENString() != c.expected {
+			t.Errorf("Expecting %s to round trip, got %s", c.expected, parsed.FENString())
+		}
+	}
+}
+
+func Test_IsDraw_bare_kings(t *testing.T) {
+	cases := map[string]bool{
+		"8/8/3k4/8/8/4K3/8/8 w - - 0 1":   true,
+		"8/8/3k4/8/8/4K3/8/8 b - - 0 1":   true,
+		"8/8/3k4/8/8/4K3/4P3/8 w - - 0 1": false,
+		"8/8/3k4/8/8/4K3/8/7q w - - 0 1":  false,
+	}
+	for fenStr, expected := range cases {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.HasOnlyKings() != expected {
+			t.Errorf("Expecting HasOnlyKings() to be %v in %s", expected, fenStr)
+		}
+		if unit.IsDraw() != expected {
+			t.Errorf("Expecting IsDraw() to be %v in %s", expected, fenStr)
+		}
+	}
+	// Capturing the last piece draws the game
+	unit, err := ParseFEN("8/8/3k4/8/4q3/4K3/8/8 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := unit.ApplyMove(MustParseMove("e3e4")); !result.IsDraw() {
+		t.Errorf("Expecting a draw after Kxe4")
+	}
+}
+
+func Test_ValidMoves_excludes_moves_that_expose_the_king(t *testing.T) {
+	cases := []struct {
+		fen     string
+		legal   []string
+		illegal []string
+	}{
+		// Pinned along the file
+		{"4r1k1/8/8/8/8/8/4N3/4K3 w - - 0 1", []string{"e1d1"}, []string{"e2c3", "e2g1", "e2f4"}},
+		{"4r1k1/8/8/8/8/8/4R3/4K3 w - - 0 1", []string{"e2e3", "e2e8"}, []string{"e2d2", "e2a2"}},
+		// Pinned along the diagonal
+		{"6k1/8/8/b7/8/8/3B4/4K3 w - - 0 1", []string{"d2c3", "d2a5"}, []string{"d2e3", "d2c1"}},
+		{"6k1/8/8/b7/8/8/3P4/4K3 w - - 0 1", []string{"e1e2"}, []string{"d2d3", "d2d4"}},
+		// Black pieces are pinned too
+		{"4k3/4q3/8/8/8/8/8/4R1K1 b - - 0 1", []string{"e7e1", "e7e4"}, []string{"e7d7", "e7a3"}},
+		// The king can't step onto an attacked square
+		{"3r2k1/8/8/8/8/8/8/4K3 w - - 0 1", []string{"e1f1", "e1e2"}, []string{"e1d1", "e1d2"}},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid := map[string]bool{}
+		for _, move := range unit.ValidMoves() {
+			valid[move.String()] = true
+		}
+		for _, move := range c.legal {
+			if !valid[move] {
+				t.Errorf("Expecting %s to be valid in %s", move, c.fen)
+			}
+		}
+		for _, move := range c.illegal {
+			if valid[move] {
+				t.Errorf("Expecting %s not to be valid in %s", move, c.fen)
+			}
+		}
+		for _, move := range unit.ValidMoves() {
+			result := unit.ApplyMove(move)
+			if result.SquareControl.AttacksSquare(result.ToMove, result.Pieces.GetKingPos(unit.ToMove)) {
+				t.Errorf("Expecting %s to not leave the king in check in %s", move, c.fen)
+			}
+		}
+	}
+}
+
+func Test_ValidMoves_king_doesnt_step_into_check(t *testing.T) {
+	cases := []struct {
+		fen     string
+		legal   []string
+		illegal []string
+	}{
+		// Undefended pieces can be captured, defended ones can't
+		{"6k1/8/8/8/8/8/3nr3/4K3 w - - 0 1", []string{"e1e2", "e1d1"}, []string{"e1d2", "e1f2", "e1f1"}},
+		{"6k1/8/8/8/8/8/3n4/4K3 w - - 0 1", []string{"e1d2", "e1e2", "e1f2", "e1d1"}, []string{}},
+		// The king can't step back along the line of the checking rook
+		{"4r1k1/8/8/8/8/8/8/4K3 w - - 0 1", []string{"e1d1", "e1f2"}, []string{"e1e2"}},
+		// The squares next to the opponent's king
+		{"8/8/8/8/3k4/8/3K4/8 w - - 0 1", []string{"d2d1", "d2e2", "d2c2"}, []string{"d2d3", "d2c3", "d2e3"}},
+		// Pawns only attack diagonally
+		{"6k1/8/8/8/8/3p4/8/4K3 w - - 0 1", []string{"e1d1", "e1d2", "e1f2"}, []string{"e1e2"}},
+		{"6k1/8/8/8/8/4p3/8/4K3 w - - 0 1", []string{"e1e2"}, []string{"e1d2", "e1f2"}},
+		// Black's king too
+		{"4k3/8/8/8/8/8/8/3RK3 b - - 0 1", []string{"e8e7", "e8f8"}, []string{"e8d8", "e8d7"}},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid := map[string]bool{}
+		for _, move := range unit.ValidMoves() {
+			valid[move.String()] = true
+		}
+		for _, move := range c.legal {
+			if !valid[move] {
+				t.Errorf("Expecting %s to be valid in %s", move, c.fen)
+			}
+		}
+		for _, move := range c.illegal {
+			if valid[move] {
+				t.Errorf("Expecting %s not to be valid in %s", move, c.fen)
+			}
+		}
+	}
+}
+
+func Test_IsDraw(t *testing.T) {
+	cases := []struct {
+		name     string
+		fen      string
+		expected bool
+	}{
+		{"fifty move rule", "4k3/8/8/8/8/8/4P3/R3K3 w - - 100 80", true},
+		{"before the fifty move rule", "4k3/8/8/8/8/8/4P3/R3K3 w - - 99 80", false},
+		{"stalemate", "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", true},
+		{"king against king", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"king and bishop against king", "4k3/8/8/8/8/8/8/2B1K3 w - - 0 1", true},
+		{"king against king and bishop", "2b1k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"king and knight against king", "4k3/8/8/8/8/8/8/1N2K3 b - - 0 1", true},
+		{"bishops on the same colour", "2b1k3/8/8/8/8/8/8/4KB2 w - - 0 1", true},
+		{"bishops on different colours", "2b1k3/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{"bishop against knight", "1n2k3/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{"two knights", "4k3/8/8/8/8/8/8/1N2K1N1 w - - 0 1", false},
+		{"king and pawn", "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", false},
+		{"king and rook", "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", false},
+		{"starting position", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", false},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.IsDraw() != c.expected {
+			t.Errorf("Expecting IsDraw() to be %v for %s in %s", c.expected, c.name, c.fen)
+		}
+	}
+}
@@ -15,11 +15,22 @@ func NewMove(from, to Position) *Move {
 	return MoveMap[int(from)*64+int(to)]
 }
 
+// Constructs a pawn move that promotes to @promote. Unlike NewMove this
+// allocates a new Move, because promotions aren't part of the MoveMap.
+func NewPromotionMove(from, to Position, promote Piece) *Move {
+	return &Move{
+		From:    from,
+		To:      to,
+		Promote: promote,
+	}
+}
+
 func (m Move) String() string {
 	if m.Promote == NoPiece {
 		return fmt.Sprintf("%v%v", m.From, m.To)
 	}
-	return fmt.Sprintf("%v%v%v", m.From, m.To, m.Promote)
+	// UCI expects the promotion piece in lowercase, regardless of colour.
+	return fmt.Sprintf("%v%v%v", m.From, m.To, m.Promote.ToNormalizedPiece())
 }
 
 func (m *Move) toPromotions(result []*Move) []*Move {
@@ -30,7 +41,7 @@ func (m *Move) toPromotions(result []*Move) []*Move {
 			color = Black
 		}
 		for _, piece := range []Piece{WhiteQueen, WhiteKnight, WhiteRook, WhiteBishop} {
-			move := &Move{m.From, m.To, piece.SetColor(color)}
+			move := NewPromotionMove(m.From, m.To, piece.SetColor(color))
 			result = append(result, move)
 		}
 		return result
@@ -117,11 +128,7 @@ func ParseMove(moveStr string) (*Move, error) {
 	if promote == NoPiece {
 		return NewMove(from, to), nil
 	}
-	return &Move{
-		From:    from,
-		To:      to,
-		Promote: promote,
-	}, nil
+	return NewPromotionMove(from, to, promote), nil
 }
 
 func MustParseMove(moveStr string) *Move {
@@ -71,3 +71,25 @@ func Test_NormalizeVector(t *testing.T) {
 	checkNormalize(t, 4, -4, 1, -1)
 	checkNormalize(t, 4, 4, 1, 1)
 }
+
+func Test_NewPromotionMove(t *testing.T) {
+	cases := map[Piece]string{
+		WhiteQueen:  "e7e8q",
+		WhiteRook:   "e7e8r",
+		WhiteBishop: "e7e8b",
+		WhiteKnight: "e7e8n",
+	}
+	for piece, expected := range cases {
+		move := NewPromotionMove(E7, E8, piece)
+		if move.Promote != piece {
+			t.Errorf("Expecting promotion to %s, got %s", piece, move.Promote)
+		}
+		if move.String() != expected {
+			t.Errorf("Expecting %s, got %s", expected, move.String())
+		}
+	}
+	move := NewPromotionMove(D2, D1, BlackKnight)
+	if move.String() != "d2d1n" {
+		t.Errorf("Expecting d2d1n, got %s", move.String())
+	}
+}
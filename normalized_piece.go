@@ -23,10 +23,26 @@ var NormalizedPieces = []NormalizedPiece{
 
 var NumberOfNormalizedPieces = 6
 
-func (p NormalizedPiece) IsRayPiece() bool {
+// The material value of every piece in centipawns
+var PieceValues = map[NormalizedPiece]int{
+	Pawn:   100,
+	Knight: 325,
+	Bishop: 325,
+	King:   400,
+	Rook:   550,
+	Queen:  1100,
+}
+
+// Whether the piece moves along lines and diagonals until it's blocked.
+func (p NormalizedPiece) IsSlider() bool {
 	return p == Bishop || p == Rook || p == Queen
 }
 
+// Returns the PieceValues value of the piece, or 0 for NoNPiece.
+func (p NormalizedPiece) Value() int {
+	return PieceValues[p]
+}
+
 func (p NormalizedPiece) ToPiece(color Color) Piece {
 	if color == Black {
 		return Piece(p)
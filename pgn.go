@@ -2,7 +2,10 @@ package chess_engine
 
 import (
 	"bytes"
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"text/template"
 )
 
@@ -43,10 +46,10 @@ func LineToPGN(position *Game, line []*Move) string {
 
 	result := ""
 	currentLine := ""
+	moveNr := position.Ply()/2 + 1
 	if position.ToMove == Black {
-		currentLine = "1. ... "
+		currentLine = strconv.Itoa(moveNr) + ". ... "
 	}
-	moveNr := 1
 
 	game := position
 	for _, move := range line {
@@ -96,7 +99,7 @@ func MoveToAlgebraicMove(position *Game, move *Move) string {
 	if normPiece == Pawn {
 		moveStr := move.To.String()
 		if move.Promote != NoPiece {
-			moveStr += pieceMap[move.Promote.ToNormalizedPiece()]
+			moveStr += "=" + pieceMap[move.Promote.ToNormalizedPiece()]
 		}
 		if capture == "" {
 			result = moveStr
@@ -141,3 +144,46 @@ func MoveToAlgebraicMove(position *Game, move *Move) string {
 	}
 	return result
 }
+
+// Parses a move in Standard Algebraic Notation (e.g. "Nf3", "exd5", "O-O") by
+// finding the valid move in @position that has the same notation.
+func ParseSAN(position *Game, san string) (*Move, error) {
+	normalize := func(san string) string {
+		san = strings.TrimRight(san, "+#!?")
+		san = strings.Replace(san, "=", "", 1)
+		return strings.Replace(san, "0", "O", -1)
+	}
+	wanted := normalize(san)
+	for _, move := range position.ValidMoves() {
+		if normalize(MoveToAlgebraicMove(position, move)) == wanted {
+			return move, nil
+		}
+	}
+	return nil, fmt.Errorf("Invalid move %s in %s", san, position.FENString())
+}
+
+// Plays the moves, given in Standard Algebraic Notation, from the current
+// position. Useful for building test positions, e.g.
+// start.ReachBySAN("e4", "e5", "Nf3")
+func (f *Game) ReachBySAN(sans ...string) (*Game, error) {
+	game := f
+	for _, san := range sans {
+		move, err := ParseSAN(game, san)
+		if err != nil {
+			return nil, err
+		}
+		game = game.ApplyMove(move)
+	}
+	return game, nil
+}
+
+// Returns every valid move in the position in Standard Algebraic Notation,
+// sorted alphabetically, e.g. to show the options in a teaching tool.
+func (f *Game) ValidMovesSAN() []string {
+	result := []string{}
+	for _, move := range f.ValidMoves() {
+		result = append(result, MoveToAlgebraicMove(f, move))
+	}
+	sort.Strings(result)
+	return result
+}
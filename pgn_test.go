@@ -0,0 +1,160 @@
+package chess_engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_LineToPGN_numbers_moves_from_position(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgn := LineToPGN(unit, []*Move{NewMove(G1, F3), NewMove(B8, C6)})
+	if pgn != "2. Nf3 Nc6 \n" {
+		t.Errorf("Expecting '2. Nf3 Nc6', got '%s'", pgn)
+	}
+	unit = unit.ApplyMove(NewMove(G1, F3))
+	pgn = LineToPGN(unit, []*Move{NewMove(B8, C6)})
+	if pgn != "2. ... Nc6 \n" {
+		t.Errorf("Expecting '2. ... Nc6', got '%s'", pgn)
+	}
+}
+
+func Test_ReachBySAN(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	game, err := unit.ReachBySAN("e4", "e5", "Nf3", "Nc6", "Bb5", "a6", "Bxc6", "dxc6", "O-O")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "r1bqkbnr/1pp2ppp/p1p5/4p3/4P3/5N2/PPPP1PPP/RNBQ1RK1 b kq - 1 5"
+	if game.FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, game.FENString())
+	}
+	if _, err := unit.ReachBySAN("e4", "Ke2"); err == nil {
+		t.Errorf("Expecting an error for an illegal move")
+	}
+}
+
+func Test_ParseSAN(t *testing.T) {
+	unit, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"Nxf7":  "e5f7",
+		"Qxf6":  "f3f6",
+		"dxe6":  "d5e6",
+		"O-O-O": "e1c1",
+		"0-0":   "e1g1",
+		"Nb1":   "c3b1",
+		"Bxa6":  "e2a6",
+		"Qxh3":  "f3h3",
+		"gxh3":  "g2h3",
+	}
+	for san, expected := range cases {
+		move, err := ParseSAN(unit, san)
+		if err != nil {
+			t.Error(err)
+		} else if move.String() != expected {
+			t.Errorf("Expecting %s to be %s, got %s", san, expected, move)
+		}
+	}
+}
+
+func Test_MoveToAlgebraicMove_promotions(t *testing.T) {
+	cases := map[string]map[string]string{
+		"3r4/4P1k1/8/8/8/8/8/K7 w - - 0 1": map[string]string{
+			"e8=Q":   "e7e8",
+			"e8=R":   "e7e8",
+			"e8=B":   "e7e8",
+			"e8=N+":  "e7e8",
+			"exd8=Q": "e7d8",
+			"exd8=R": "e7d8",
+			"exd8=B": "e7d8",
+			"exd8=N": "e7d8",
+		},
+		"7k/8/8/8/8/8/5p2/K5N1 b - - 0 1": map[string]string{
+			"f1=Q+":   "f2f1",
+			"f1=R+":   "f2f1",
+			"f1=B":    "f2f1",
+			"f1=N":    "f2f1",
+			"fxg1=Q+": "f2g1",
+			"fxg1=R+": "f2g1",
+			"fxg1=B":  "f2g1",
+			"fxg1=N":  "f2g1",
+		},
+	}
+	for fenStr, expected := range cases {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := 0
+		for _, move := range unit.ValidMoves() {
+			if move.Promote == NoPiece {
+				continue
+			}
+			seen++
+			san := MoveToAlgebraicMove(unit, move)
+			if expected[san] == "" || expected[san]+move.Promote.ToNormalizedPiece().String() != move.String() {
+				t.Errorf("Unexpected notation %s for %s in %s", san, move, fenStr)
+			}
+			if move.Promote.Color() != unit.ToMove {
+				t.Errorf("Expecting %s to promote to a piece for %s", move, unit.ToMove)
+			}
+			parsed, err := ParseSAN(unit, san)
+			if err != nil {
+				t.Error(err)
+			} else if parsed.String() != move.String() || parsed.Promote != move.Promote {
+				t.Errorf("Expecting %s to parse as %s, got %s", san, move, parsed)
+			}
+		}
+		if seen != len(expected) {
+			t.Errorf("Expecting %d promotions in %s, got %d", len(expected), fenStr, seen)
+		}
+	}
+}
+
+func Test_ValidMovesSAN(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Na3", "Nc3", "Nf3", "Nh3",
+		"a3", "a4", "b3", "b4", "c3", "c4", "d3", "d4",
+		"e3", "e4", "f3", "f4", "g3", "g4", "h3", "h4",
+	}
+	sans := unit.ValidMovesSAN()
+	if strings.Join(sans, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expecting %v, got %v", expected, sans)
+	}
+
+	// Two rooks and both knights can reach d1, and two rooks on the same
+	// file can reach a3, so they need to be told apart.
+	unit, err = ParseFEN("4k3/8/8/R7/8/8/1N3N2/R4RK1 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sans = unit.ValidMovesSAN()
+	for _, san := range []string{"Rad1", "Rfd1", "Nbd1", "Nfd1", "R1a3", "R5a3", "Rd5"} {
+		found := false
+		for _, s := range sans {
+			found = found || s == san
+		}
+		if !found {
+			t.Errorf("Expecting %s in %v", san, sans)
+		}
+	}
+	for _, san := range []string{"Rd1", "Nd1", "Ra3"} {
+		for _, s := range sans {
+			if s == san {
+				t.Errorf("Expecting %s to be disambiguated in %v", san, sans)
+			}
+		}
+	}
+}
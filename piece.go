@@ -95,10 +95,14 @@ func ParsePiece(b byte) (Piece, error) {
 	return piece, nil
 }
 
-func (p Piece) IsRayPiece() bool {
+func (p Piece) IsSlider() bool {
 	return p == BlackQueen || p == WhiteQueen || p == BlackBishop || p == WhiteBishop || p == BlackRook || p == WhiteRook
 }
 
+func (p Piece) Value() int {
+	return p.ToNormalizedPiece().Value()
+}
+
 func (p Piece) Color() Color {
 	if p <= BlackKing {
 		return Black
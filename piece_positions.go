@@ -62,7 +62,12 @@ func (p PiecePositions) Phase() int {
 		phase += phaseScore[piece] * p[White][piece].Count()
 		phase += phaseScore[piece] * p[Black][piece].Count()
 	}
-	return phase // Max is 256 (16*2=32, 6*4=24, 12*4=48, 16*4=64, 44*2=88, 32+24+48+64+88=256)
+	// Max is 256 (16*2=32, 6*4=24, 12*4=48, 16*4=64, 44*2=88, 32+24+48+64+88=256),
+	// but promoting pawns to extra queens can take us over that.
+	if phase > 256 {
+		phase = 256
+	}
+	return phase
 }
 
 func (p PiecePositions) Count() int {
@@ -0,0 +1,53 @@
+package chess_engine
+
+import "testing"
+
+func Test_IsSlider(t *testing.T) {
+	sliders := map[NormalizedPiece]bool{
+		Pawn:     false,
+		Knight:   false,
+		Bishop:   true,
+		Rook:     true,
+		Queen:    true,
+		King:     false,
+		NoNPiece: false,
+	}
+	for piece, expected := range sliders {
+		if piece.IsSlider() != expected {
+			t.Errorf("Expecting IsSlider() to be %v for %s", expected, piece)
+		}
+		for _, color := range Colors {
+			if piece != NoNPiece && piece.ToPiece(color).IsSlider() != expected {
+				t.Errorf("Expecting IsSlider() to be %v for %s", expected, piece.ToPiece(color))
+			}
+		}
+	}
+	if NoPiece.IsSlider() {
+		t.Errorf("Expecting NoPiece to not be a slider")
+	}
+}
+
+func Test_Value(t *testing.T) {
+	values := map[NormalizedPiece]int{
+		Pawn:     100,
+		Knight:   325,
+		Bishop:   325,
+		Rook:     550,
+		Queen:    1100,
+		King:     400,
+		NoNPiece: 0,
+	}
+	for piece, expected := range values {
+		if piece.Value() != expected {
+			t.Errorf("Expecting %s to be worth %d, got %d", piece, expected, piece.Value())
+		}
+		for _, color := range Colors {
+			if piece != NoNPiece && piece.ToPiece(color).Value() != expected {
+				t.Errorf("Expecting %s to be worth %d, got %d", piece.ToPiece(color), expected, piece.ToPiece(color).Value())
+			}
+		}
+	}
+	if NoPiece.Value() != 0 {
+		t.Errorf("Expecting NoPiece to be worth nothing, got %d", NoPiece.Value())
+	}
+}
@@ -163,6 +163,28 @@ func (p Position) GetFile() File {
 	file := p % 8
 	return File(file + 'a')
 }
+
+// Whether @p is a light square; a1 is dark.
+func (p Position) IsLightSquare() bool {
+	return (int(p.GetFile()-FileA)+int(p.GetRank()-'1'))%2 == 1
+}
+
+// Returns the number of king moves it takes to get from @p to @p2.
+func (p Position) ChebyshevDistance(p2 Position) int {
+	fileDiff := int(p.GetFile()) - int(p2.GetFile())
+	if fileDiff < 0 {
+		fileDiff = -fileDiff
+	}
+	rankDiff := int(p.GetRank()) - int(p2.GetRank())
+	if rankDiff < 0 {
+		rankDiff = -rankDiff
+	}
+	if fileDiff > rankDiff {
+		return fileDiff
+	}
+	return rankDiff
+}
+
 func (p Position) GetWhitePawnAttacks() []Position {
 	positions := []Position{}
 	file, rank := p.GetFile(), p.GetRank()
@@ -44,3 +44,35 @@ func (p PositionBitmap) ToPositions() []Position {
 	}
 	return result
 }
+
+// The squares on the a-file and the first rank. The other files and ranks
+// are shifted from these.
+const (
+	fileAMask PositionBitmap = 0x0101010101010101
+	rank1Mask PositionBitmap = 0xff
+)
+
+// Returns the bitmap with every square on @file set.
+func FileMask(file File) PositionBitmap {
+	if file < FileA || file > FileH {
+		return 0
+	}
+	return fileAMask << uint(file-FileA)
+}
+
+// Returns the bitmap with every square on @rank set.
+func RankMask(rank Rank) PositionBitmap {
+	if rank < Rank1 || rank > Rank8 {
+		return 0
+	}
+	return rank1Mask << (8 * uint(rank-Rank1))
+}
+
+// Returns the bitmap with every square on the files next to @file set, e.g.
+// to find isolated or passed pawns.
+func AdjacentFilesMask(file File) PositionBitmap {
+	if file < FileA || file > FileH {
+		return 0
+	}
+	return FileMask(file-1) | FileMask(file+1)
+}
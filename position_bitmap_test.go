@@ -59,3 +59,36 @@ func Test_PositionBitmap(t *testing.T) {
 		t.Errorf("Expecting e5 to be unset")
 	}
 }
+
+func Test_FileMask_RankMask_and_AdjacentFilesMask(t *testing.T) {
+	if FileMask(FileA).Count() != 8 || !FileMask(FileA).IsSet(A1) || !FileMask(FileA).IsSet(A8) {
+		t.Errorf("Expecting the a-file mask to have a1 to a8, got %v", FileMask(FileA).ToPositions())
+	}
+	if FileMask(FileA)&FileMask(FileB) != 0 || FileMask(FileH).IsSet(A2) {
+		t.Errorf("Expecting the file masks not to bleed into other files")
+	}
+	if RankMask(Rank1).Count() != 8 || !RankMask(Rank8).IsSet(H8) || RankMask(Rank8).IsSet(H7) {
+		t.Errorf("Expecting the rank masks to have the eight squares on the rank")
+	}
+	if AdjacentFilesMask(FileA) != FileMask(FileB) {
+		t.Errorf("Expecting only the b-file next to the a-file, got %v", AdjacentFilesMask(FileA).ToPositions())
+	}
+	if AdjacentFilesMask(FileH) != FileMask(FileG) {
+		t.Errorf("Expecting only the g-file next to the h-file, got %v", AdjacentFilesMask(FileH).ToPositions())
+	}
+	if AdjacentFilesMask(FileD) != FileMask(FileC)|FileMask(FileE) || AdjacentFilesMask(FileD).Count() != 16 {
+		t.Errorf("Expecting the c and e-files next to the d-file, got %v", AdjacentFilesMask(FileD).ToPositions())
+	}
+	all := PositionBitmap(0)
+	for file := FileA; file <= FileH; file++ {
+		all |= FileMask(file)
+		for rank := Rank1; rank <= Rank8; rank++ {
+			if FileMask(file)&RankMask(rank) != PositionBitmap(0).Add(PositionFromFileRank(file, rank)) {
+				t.Errorf("Expecting the file and rank masks to meet on %c%c", file, rank)
+			}
+		}
+	}
+	if all.Count() != 64 || FileMask(NoFile) != 0 || RankMask(NoRank) != 0 {
+		t.Errorf("Expecting the file masks to cover the board")
+	}
+}
@@ -59,3 +59,189 @@ func Test_ParsePosition(t *testing.T) {
 		}
 	}
 }
+
+func Test_Position_constants_are_board_indices(t *testing.T) {
+	if E2 != 12 {
+		t.Errorf("Expecting e2 to be index 12, got %d", E2)
+	}
+	if A1 != 0 || H8 != 63 {
+		t.Errorf("Expecting a1..h8 to span 0..63, got %d..%d", A1, H8)
+	}
+	move := NewMove(E2, E4)
+	if move.String() != "e2e4" {
+		t.Errorf("Expecting e2e4, got %s", move.String())
+	}
+}
+
+func Test_Position_round_trips(t *testing.T) {
+	for pos := A1; pos <= H8; pos++ {
+		parsed, err := ParsePosition(pos.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != pos {
+			t.Errorf("Expecting %s to parse as %d, got %d", pos, pos, parsed)
+		}
+		if fromFileRank := PositionFromFileRank(pos.GetFile(), pos.GetRank()); fromFileRank != pos {
+			t.Errorf("Expecting the file and rank of %s to give %d, got %d", pos, pos, fromFileRank)
+		}
+	}
+	// The en passant square written by ApplyMove is the one ParseFEN reads
+	cases := [][]string{
+		[]string{"rnbqkbnr/ppp1pppp/8/8/3p4/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4", "e3"},
+		[]string{"rnbqkbnr/pppppppp/8/4P3/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1", "d7d5", "d6"},
+	}
+	for _, c := range cases {
+		game, err := ParseFEN(c[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		game = game.ApplyMove(MustParseMove(c[1]))
+		if game.EnPassantVulnerable != MustParsePosition(c[2]) {
+			t.Errorf("Expecting %s to be the en passant square after %s, got %s", c[2], c[1], game.EnPassantVulnerable)
+		}
+		parsed, err := ParseFEN(game.FENString())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.EnPassantVulnerable != game.EnPassantVulnerable {
+			t.Errorf("Expecting the en passant square %s to round trip, got %s", game.EnPassantVulnerable, parsed.EnPassantVulnerable)
+		}
+	}
+}
+
+func Test_PawnOpeningJump(t *testing.T) {
+	if !E2.CanPawnOpeningJump(White) || E3.CanPawnOpeningJump(White) || E7.CanPawnOpeningJump(White) {
+		t.Errorf("Expecting only white pawns on the second rank to be able to jump")
+	}
+	if !E7.CanPawnOpeningJump(Black) || E6.CanPawnOpeningJump(Black) || E2.CanPawnOpeningJump(Black) {
+		t.Errorf("Expecting only black pawns on the seventh rank to be able to jump")
+	}
+	if E2.GetPawnOpeningJump(White) != E4 || E7.GetPawnOpeningJump(Black) != E5 {
+		t.Errorf("Expecting the opening jumps to go two squares forward")
+	}
+	if !E4.IsPawnOpeningJump(White) || E3.IsPawnOpeningJump(White) || E5.IsPawnOpeningJump(White) {
+		t.Errorf("Expecting only the fourth rank to be opening jump target for white")
+	}
+	if !E5.IsPawnOpeningJump(Black) || E6.IsPawnOpeningJump(Black) || E4.IsPawnOpeningJump(Black) {
+		t.Errorf("Expecting only the fifth rank to be opening jump target for black")
+	}
+}
+
+func Test_IsPawnAttack_edges(t *testing.T) {
+	cases := []struct {
+		pos      Position
+		color    Color
+		expected []Position
+	}{
+		{A2, White, []Position{B3}},
+		{H2, White, []Position{G3}},
+		{A7, Black, []Position{B6}},
+		{H7, Black, []Position{G6}},
+		{A4, White, []Position{B5}},
+		{H5, Black, []Position{G4}},
+	}
+	for _, c := range cases {
+		for target := A1; target <= H8; target++ {
+			isExpected := false
+			for _, e := range c.expected {
+				if e == target {
+					isExpected = true
+				}
+			}
+			if c.pos.IsPawnAttack(target, c.color) != isExpected {
+				t.Errorf("Expecting IsPawnAttack(%s) for %s pawn on %s to be %v", target, c.color, c.pos, isExpected)
+			}
+		}
+	}
+}
+
+func Test_ChebyshevDistance(t *testing.T) {
+	cases := []struct {
+		from, to Position
+		expected int
+	}{
+		{E4, E4, 0},
+		{E4, E5, 1},
+		{E4, F5, 1},
+		{E4, E6, 2},
+		{A1, H8, 7},
+		{A1, H2, 7},
+		{H1, A1, 7},
+		{C3, E4, 2},
+	}
+	for _, c := range cases {
+		if c.from.ChebyshevDistance(c.to) != c.expected {
+			t.Errorf("Expecting distance %d between %s and %s, got %d", c.expected, c.from, c.to, c.from.ChebyshevDistance(c.to))
+		}
+	}
+}
+
+func Test_GetKingMoves_and_GetKnightMoves_match_the_board_geometry(t *testing.T) {
+	kingOffsets := [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}, {1, 1}}
+	knightOffsets := [][2]int{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}}
+	// Computes the moves on demand, so that squares on the edges don't wrap
+	// around to the other side of the board.
+	compute := func(pos Position, offsets [][2]int) PositionBitmap {
+		result := PositionBitmap(0)
+		file, rank := int(pos%8), int(pos/8)
+		for _, offset := range offsets {
+			f, r := file+offset[0], rank+offset[1]
+			if f >= 0 && f < 8 && r >= 0 && r < 8 {
+				result = result.Add(Position(r*8 + f))
+			}
+		}
+		return result
+	}
+	toBitmap := func(moves []Position) PositionBitmap {
+		result := PositionBitmap(0)
+		for _, m := range moves {
+			result = result.Add(m)
+		}
+		return result
+	}
+	for pos := A1; pos <= H8; pos++ {
+		king, knight := pos.GetKingMoves(), pos.GetKnightMoves()
+		if expected := compute(pos, kingOffsets); toBitmap(king) != expected || len(king) != expected.Count() {
+			t.Errorf("Expecting king moves %v on %s, got %v", expected.ToPositions(), pos, king)
+		}
+		if expected := compute(pos, knightOffsets); toBitmap(knight) != expected || len(knight) != expected.Count() {
+			t.Errorf("Expecting knight moves %v on %s, got %v", expected.ToPositions(), pos, knight)
+		}
+	}
+	cases := map[Position][]int{
+		A1: []int{3, 2},
+		H1: []int{3, 2},
+		A8: []int{3, 2},
+		H8: []int{3, 2},
+		A4: []int{5, 4},
+		B2: []int{8, 4},
+		E4: []int{8, 8},
+	}
+	for pos, expected := range cases {
+		if len(pos.GetKingMoves()) != expected[0] {
+			t.Errorf("Expecting %d king moves on %s, got %d", expected[0], pos, len(pos.GetKingMoves()))
+		}
+		if len(pos.GetKnightMoves()) != expected[1] {
+			t.Errorf("Expecting %d knight moves on %s, got %d", expected[1], pos, len(pos.GetKnightMoves()))
+		}
+	}
+}
+
+func Test_IsLightSquare(t *testing.T) {
+	cases := map[Position]bool{
+		A1: false,
+		B1: true,
+		H1: true,
+		A8: true,
+		H8: false,
+		D1: true,
+		E4: true,
+		D4: false,
+	}
+	for pos, expected := range cases {
+		if pos.IsLightSquare() != expected {
+			t.Errorf("Expecting IsLightSquare() for %s to be %v", pos, expected)
+		}
+	}
+}
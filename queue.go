@@ -53,6 +53,15 @@ func (q *Queue) QueueNextLine(pos *Game, seen SeenMap, depth int, evaluators Eva
 	return false
 }
 
+// Queues every position reachable from @pos in one move. The seen map is
+// not consulted, because skipping transpositions would leave holes in the
+// evaluation tree.
+func (q *Queue) QueueAllLines(pos *Game) {
+	for _, nextGame := range pos.NextGames() {
+		q.List.PushFront(nextGame)
+	}
+}
+
 func (q *Queue) QueueToQuietPosition(pos *Game, seen SeenMap, depth int, evaluators Evaluators) bool {
 
 	newLine, _ := evaluators.GetLineToQuietPosition(pos, depth)
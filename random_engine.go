@@ -1,12 +1,15 @@
 package chess_engine
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 )
 
 type RandomEngine struct {
 	StartingPosition *Game
+	// Seeded with the RANDOMSEED option, so that games can be reproduced
+	Rand *rand.Rand
 }
 
 func NewRandomEngine() *RandomEngine {
@@ -23,9 +26,20 @@ func (b *RandomEngine) AddEvaluator(eval Evaluator) {
 }
 func (b *RandomEngine) Start(output chan string, maxNodes, maxDepth int) {
 	nextGames := b.StartingPosition.NextGames()
-	board := nextGames[rand.Intn(len(nextGames))]
+	if len(nextGames) == 0 {
+		outputNoMoves(context.Background(), output, b.StartingPosition)
+		return
+	}
+	if b.Rand == nil {
+		b.Rand = rand.New(rand.NewSource(rand.Int63()))
+	}
+	board := nextGames[b.Rand.Intn(len(nextGames))]
 	output <- fmt.Sprintf("bestmove %s", board.Line[0])
 }
 
-func (b *RandomEngine) Stop()                               {}
-func (b *RandomEngine) SetOption(opt EngineOption, val int) {}
+func (b *RandomEngine) Stop() {}
+func (b *RandomEngine) SetOption(opt EngineOption, val int) {
+	if opt == RANDOMSEED {
+		b.Rand = rand.New(rand.NewSource(int64(val)))
+	}
+}
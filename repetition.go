@@ -0,0 +1,65 @@
+package chess_engine
+
+// The maximum number of positions a RepetitionStack can hold, which is more
+// than any game or search line will need.
+const MaxRepetitionStackSize = 1024
+
+// Keeps track of the Zobrist hashes of the positions leading up to the
+// current one, so that repetitions can be found without building FEN
+// strings. Push a hash when making a move and Pop it when taking it back.
+//
+// Only the depth first searches, like FindMate, make and take back moves.
+// BSEngine takes its positions off a queue in no particular order, so there
+// is no line to push and pop; it follows the Parent games instead, in
+// Game.RepetitionCountInLine, which compares the same hashes.
+type RepetitionStack struct {
+	keys [MaxRepetitionStackSize]uint64
+	size int
+}
+
+func NewRepetitionStack() *RepetitionStack {
+	return &RepetitionStack{}
+}
+
+func (r *RepetitionStack) Push(key uint64) {
+	if r.size == MaxRepetitionStackSize {
+		panic("repetition stack is full")
+	}
+	r.keys[r.size] = key
+	r.size++
+}
+
+func (r *RepetitionStack) Pop() uint64 {
+	if r.size == 0 {
+		panic("repetition stack is empty")
+	}
+	r.size--
+	return r.keys[r.size]
+}
+
+func (r *RepetitionStack) Len() int {
+	return r.size
+}
+
+// Returns how many times the position on top of the stack occurs, including
+// the top itself. The side to move is part of the hash, so we don't have to
+// skip over the positions where the other side is to move.
+func (r *RepetitionStack) Count() int {
+	if r.size == 0 {
+		return 0
+	}
+	key := r.keys[r.size-1]
+	count := 0
+	for i := 0; i < r.size; i++ {
+		if r.keys[i] == key {
+			count++
+		}
+	}
+	return count
+}
+
+// Whether the position on top of the stack has occurred at least twice
+// before.
+func (r *RepetitionStack) IsThreefold() bool {
+	return r.Count() >= 3
+}
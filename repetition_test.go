@@ -0,0 +1,59 @@
+package chess_engine
+
+import "testing"
+
+func Test_RepetitionStack_detects_threefold(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stack := NewRepetitionStack()
+	stack.Push(unit.Hash())
+	line := []string{"g1f3", "g8f6", "f3g1", "f6g8", "g1f3", "g8f6", "f3g1", "f6g8"}
+	games := []*Game{unit}
+	for i, moveStr := range line {
+		game := games[len(games)-1].ApplyMove(MustParseMove(moveStr))
+		games = append(games, game)
+		stack.Push(game.Hash())
+		expected := (i+1)/4 + 1
+		if stack.Count() != expected {
+			t.Errorf("Expecting %d occurrences after %s, got %d", expected, Line(game.Line), stack.Count())
+		}
+		if stack.Count() != game.RepetitionCountInLine() {
+			t.Errorf("Expecting the stack to agree with RepetitionCountInLine after %s", Line(game.Line))
+		}
+		if stack.IsThreefold() != (expected == 3) {
+			t.Errorf("Expecting IsThreefold() to be %v after %s", expected == 3, Line(game.Line))
+		}
+	}
+	// Taking the moves back should get us back to where we started
+	for i := len(line); i > 0; i-- {
+		if key := stack.Pop(); key != games[i].Hash() {
+			t.Errorf("Expecting to pop %#x for %s, got %#x", games[i].Hash(), Line(games[i].Line), key)
+		}
+		if stack.IsThreefold() {
+			t.Errorf("Not expecting a threefold repetition after taking back %s", line[i-1])
+		}
+	}
+	if stack.Len() != 1 || stack.Count() != 1 {
+		t.Errorf("Expecting only the starting position to be left, got %d entries", stack.Len())
+	}
+}
+
+func Test_findMate_keeps_the_repetition_stack_balanced(t *testing.T) {
+	// With and without a mate to find
+	for _, fenStr := range []string{"6k1/5ppp/8/8/8/8/r4PPP/1R4K1 w - - 0 1", "4k3/8/8/8/8/8/8/4K2R w K - 0 1"} {
+		unit, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, moves := range []int{1, 2, 3} {
+			stack := NewRepetitionStack()
+			stack.Push(unit.Hash())
+			findMate(unit, moves, stack)
+			if stack.Len() != 1 {
+				t.Errorf("Expecting one entry on the stack after looking for mate in %d in %s, got %d", moves, fenStr, stack.Len())
+			}
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"math"
 )
 
+// Scores are expressed in centipawns.
 type Score int64
 
 const (
@@ -28,6 +29,12 @@ func (s Score) IsMateInNOrBetter(n int) bool {
 	return s >= lowerBound
 }
 
+// Mate scores get reduced by the number of plies it takes to deliver mate, so
+// anything close to Mate is a mate for the side to move.
+func (s Score) IsMateScore() bool {
+	return s <= Mate && s.IsMateInNOrBetter(1000)
+}
+
 func (s Score) Format(c Color) string {
 	sign := ""
 	score := float64(s) / 100
@@ -8,9 +8,9 @@ func NewSeenMap() SeenMap {
 }
 
 func (s SeenMap) Seen(g *Game) bool {
-	return s[g.FENString()]
+	return s[g.SeenKey()]
 }
 
 func (s SeenMap) Set(g *Game) {
-	s[g.FENString()] = true
+	s[g.SeenKey()] = true
 }
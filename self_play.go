@@ -0,0 +1,91 @@
+package chess_engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// How long SelfPlay waits for an engine to play a move before giving up on
+// the game.
+var SelfPlayMoveTimeout = time.Minute
+
+type GameResult uint8
+
+const (
+	Unfinished GameResult = iota
+	WhiteWins
+	BlackWins
+	Drawn
+)
+
+func (g GameResult) String() string {
+	if g == WhiteWins {
+		return "1-0"
+	} else if g == BlackWins {
+		return "0-1"
+	} else if g == Drawn {
+		return "1/2-1/2"
+	}
+	return "*"
+}
+
+// Plays a game between @white and @black from the starting position, until
+// it's mate, a draw, or @maxMoves half moves have been played. The returned
+// Game's Line holds every move that was played. An error is returned if one
+// of the engines plays an illegal move.
+func SelfPlay(white, black Engine, maxMoves int) (*Game, GameResult, error) {
+	game, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		return nil, Unfinished, err
+	}
+	for len(game.Line) < maxMoves {
+		if game.IsMate() {
+			if game.ToMove == White {
+				return game, BlackWins, nil
+			}
+			return game, WhiteWins, nil
+		} else if game.IsDraw() {
+			return game, Drawn, nil
+		}
+		engine := white
+		if game.ToMove == Black {
+			engine = black
+		}
+		move, err := playMove(engine, game)
+		if err != nil {
+			return game, Unfinished, err
+		}
+		game = game.ApplyMove(move)
+	}
+	return game, Unfinished, nil
+}
+
+// Asks @engine for its best move in @game and checks that it's valid.
+func playMove(engine Engine, game *Game) (*Move, error) {
+	output := make(chan string, 50)
+	engine.SetPosition(game.AsSearchRoot())
+	engine.Start(output, -1, -1)
+	defer engine.Stop()
+	timeout := time.NewTimer(SelfPlayMoveTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case line := <-output:
+			if !strings.HasPrefix(line, "bestmove ") {
+				continue
+			}
+			moveStr := strings.Fields(line)[1]
+			// The promotion in the engine's output doesn't have a colour,
+			// so we look the move up instead of parsing it.
+			for _, valid := range game.ValidMoves() {
+				if valid.String() == moveStr {
+					return valid, nil
+				}
+			}
+			return nil, fmt.Errorf("Engine played an illegal move %s in %s", moveStr, game.FENString())
+		case <-timeout.C:
+			return nil, fmt.Errorf("Engine didn't play a move within %s in %s", SelfPlayMoveTimeout, game.FENString())
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package chess_engine
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_SelfPlay(t *testing.T) {
+	newEngine := func() Engine {
+		engine := NewBSEngine(2)
+		engine.AddEvaluator(NaiveMaterialEvaluator)
+		engine.AddEvaluator(SpaceEvaluator)
+		return engine
+	}
+	cases := map[string][]Engine{
+		"bs-engine": []Engine{newEngine(), newEngine()},
+		"random":    []Engine{NewRandomEngine(), NewRandomEngine()},
+	}
+	for name, engines := range cases {
+		game, result, err := SelfPlay(engines[0], engines[1], 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Replaying the game from the start should only find valid moves
+		replay, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, move := range game.Line {
+			if !replay.IsLegalMove(move) {
+				t.Fatalf("Expecting %s to be legal in %s (%s)", move, replay.FENString(), name)
+			}
+			replay = replay.ApplyMove(move)
+		}
+		if replay.FENString() != game.FENString() {
+			t.Errorf("Expecting the replay to end in %s, got %s (%s)", game.FENString(), replay.FENString(), name)
+		}
+		if result == Unfinished && len(game.Line) != 30 {
+			t.Errorf("Expecting an unfinished game to have 30 moves, got %d (%s)", len(game.Line), name)
+		}
+		if result != Unfinished && !game.IsFinished() {
+			t.Errorf("Expecting the game to be finished with %s (%s)", result, name)
+		}
+	}
+}
+
+func Test_SelfPlay_against_a_random_player(t *testing.T) {
+	for seed := 1; seed <= 4; seed++ {
+		engine := NewBSEngine(2)
+		engine.AddEvaluator(NaiveMaterialEvaluator)
+		random := NewRandomEngine()
+		random.SetOption(RANDOMSEED, seed)
+		white, black := Engine(engine), Engine(random)
+		if seed%2 == 0 {
+			white, black = black, white
+		}
+		game, result, err := SelfPlay(white, black, 80)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == Unfinished && len(game.Line) != 80 {
+			t.Errorf("Expecting an unfinished game to have 80 moves, got %d (seed %d)", len(game.Line), seed)
+		}
+		replay, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, move := range game.Line {
+			if !replay.IsLegalMove(move) {
+				t.Fatalf("Expecting %s to be legal in %s (seed %d)", move, replay.FENString(), seed)
+			}
+			replay = replay.ApplyMove(move)
+			assertConsistent(t, replay)
+		}
+	}
+}
+
+// An engine that never plays a move.
+type silentEngine struct {
+	RandomEngine
+}
+
+func (s *silentEngine) Start(output chan string, maxNodes, maxDepth int) {
+	output <- "info depth 1"
+}
+
+func Test_SelfPlay_gives_up_on_an_engine_that_doesnt_play_a_move(t *testing.T) {
+	defer func(timeout time.Duration) { SelfPlayMoveTimeout = timeout }(SelfPlayMoveTimeout)
+	SelfPlayMoveTimeout = 100 * time.Millisecond
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := SelfPlay(&silentEngine{}, NewRandomEngine(), 10)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Expecting an error when the engine doesn't play a move")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expecting SelfPlay to give up on the engine")
+	}
+}
+
+// Checks that @game is a position that can arise in a real game, and that
+// it's the same position when it's parsed again from its FEN string.
+func assertConsistent(t *testing.T, game *Game) {
+	fenStr := game.FENString()
+	for _, color := range Colors {
+		if count := game.Pieces[color][King].Count(); count != 1 {
+			t.Fatalf("Expecting one %s king in %s, got %d", color, fenStr, count)
+		}
+	}
+	if err := game.Validate(); err != nil {
+		t.Fatalf("Expecting %s to be valid, got %s", fenStr, err)
+	}
+	opponentKing := game.Pieces.GetKingPos(game.ToMove.Opposite())
+	if game.SquareControl.AttacksSquare(game.ToMove, opponentKing) {
+		t.Fatalf("Expecting the side that just moved not to be in check in %s", fenStr)
+	}
+	for pos, piece := range game.Board {
+		if piece != NoPiece && !game.Pieces[piece.Color()][piece.ToNormalizedPiece()].IsSet(Position(pos)) {
+			t.Fatalf("Expecting %s on %s to be in the piece positions in %s", piece, Position(pos), fenStr)
+		}
+	}
+	parsed, err := ParseFEN(fenStr)
+	if err != nil {
+		t.Fatalf("Expecting %s to parse, got %s", fenStr, err)
+	}
+	expected := map[string]bool{}
+	for _, move := range parsed.ValidMoves() {
+		expected[move.String()] = true
+	}
+	moves := game.ValidMoves()
+	if len(moves) != len(expected) {
+		t.Fatalf("Expecting %d valid moves in %s, got %d", len(expected), fenStr, len(moves))
+	}
+	for _, move := range moves {
+		if !expected[move.String()] {
+			t.Fatalf("Expecting %s not to be valid in %s", move, fenStr)
+		}
+	}
+}
+
+func Test_GameResult_String(t *testing.T) {
+	cases := map[GameResult]string{
+		Unfinished: "*",
+		WhiteWins:  "1-0",
+		BlackWins:  "0-1",
+		Drawn:      "1/2-1/2",
+	}
+	for result, expected := range cases {
+		if result.String() != expected {
+			t.Errorf("Expecting %s, got %s", expected, result.String())
+		}
+	}
+}
@@ -83,6 +83,29 @@ func (s SquareControl) GetAttacksOnSquare(color Color, pos Position) []*Move {
 	return result
 }
 
+// Get all the captures @color can make on the @board by looking up the
+// attackers of every opposing piece, instead of following all the piece
+// vectors. Ignores pins, and lets the king take defended pieces; see
+// Game.ValidCaptures for the legal captures.
+func (s SquareControl) GetCaptures(color Color, board Board) []*Move {
+	result := []*Move{}
+	for pos, piece := range board {
+		if piece == NoPiece || piece.Color() == color {
+			continue
+		}
+		for _, from := range s.Get(color, Position(pos)).ToPositions() {
+			// Attacks by ray pieces continue through the king, so we
+			// have to make sure the line is actually clear.
+			if board[from].IsSlider() && !board.HasClearLineTo(from, Position(pos)) {
+				continue
+			}
+			move := NewMove(from, Position(pos))
+			result = move.ExpandPromotions(result, board[from].ToNormalizedPiece())
+		}
+	}
+	return result
+}
+
 func (s SquareControl) getAttacksOnSquareForBothColours(pos Position) []Position {
 	result := []Position{}
 	for _, p := range s.Get(White, pos).ToPositions() {
@@ -99,6 +122,18 @@ func (s SquareControl) AttacksSquare(color Color, square Position) bool {
 	return !s.Get(color, square).IsEmpty()
 }
 
+// Returns all the squares that @color attacks, including the squares that
+// are occupied by its own pieces.
+func (s SquareControl) AttackedSquares(color Color) PositionBitmap {
+	result := PositionBitmap(0)
+	for pos := 0; pos < 64; pos++ {
+		if s.AttacksSquare(color, Position(pos)) {
+			result = result.Add(Position(pos))
+		}
+	}
+	return result
+}
+
 func (s SquareControl) Copy() SquareControl {
 	result := make([]PositionBitmap, 128)
 	copy(result, s)
@@ -122,7 +157,7 @@ func (s SquareControl) GetPinnedPieces(board Board, color Color, kingPos Positio
 					piece := board[attackerPos]
 					normPiece := piece.ToNormalizedPiece()
 					// Pawns, kings and knights can't pin other pieces
-					if !normPiece.IsRayPiece() {
+					if !normPiece.IsSlider() {
 						continue
 					}
 					// Check if the attacker and the potentially pinned piece share the same
@@ -196,7 +231,7 @@ func (s SquareControl) ApplyMove(move *Move, piece, capturedPiece Piece, board B
 		}
 
 		// Not relevant for Pawns and Knights and King
-		if !extendPiece.IsRayPiece() {
+		if !extendPiece.IsSlider() {
 			continue
 		}
 		vector := NewMove(move.From, fromPos).Vector().Normalize()
@@ -213,7 +248,7 @@ func (s SquareControl) ApplyMove(move *Move, piece, capturedPiece Piece, board B
 			extendPiece := board[fromPos]
 			color := extendPiece.Color()
 			// Not relevant for Pawns and Knights and King
-			if !extendPiece.IsRayPiece() {
+			if !extendPiece.IsSlider() {
 				continue
 			}
 			vector := NewMove(*enpassant, fromPos).Vector().Normalize()
@@ -249,7 +284,7 @@ func (s SquareControl) ApplyMove(move *Move, piece, capturedPiece Piece, board B
 			blockPiece := board[fromPos]
 			color := blockPiece.Color()
 			// Not relevant for Pawns and Knights and King
-			if !blockPiece.IsRayPiece() {
+			if !blockPiece.IsSlider() {
 				continue
 			}
 			vector := NewMove(move.To, fromPos).Vector().Normalize()
@@ -283,3 +283,88 @@ func Test_SquareControl_GetPinnedPieces(t *testing.T) {
 		t.Errorf("Supposed to have a pinned piece")
 	}
 }
+
+func Test_SquareControl_GetCaptures(t *testing.T) {
+	cases := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		// The rook's attack continues through the king, but it can't
+		// capture the knight.
+		"4k2n/8/8/8/8/8/8/R3K3 w - - 0 1",
+	}
+	for _, fenStr := range cases {
+		fen, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, color := range Colors {
+			expected := map[string]bool{}
+			for _, move := range fen.validMoves.ToMoves(color, fen.Pieces, fen.Board) {
+				if fen.Board.IsOpposingPiece(move.To, color) {
+					expected[move.String()] = true
+				}
+			}
+			captures := fen.SquareControl.GetCaptures(color, fen.Board)
+			if len(captures) != len(expected) {
+				t.Errorf("Expecting %d captures for %s in %s, got %v", len(expected), color, fenStr, captures)
+			}
+			for _, move := range captures {
+				if !expected[move.String()] {
+					t.Errorf("Unexpected capture %s for %s in %s", move, color, fenStr)
+				}
+			}
+		}
+	}
+}
+
+func Test_SquareControl_AttackedSquares(t *testing.T) {
+	fen, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second and third ranks, and the first rank except for the corners
+	for _, color := range Colors {
+		attacked := fen.AttackedSquares(color)
+		if attacked.Count() != 22 {
+			t.Errorf("Expecting %s to attack 22 squares, got %d", color, attacked.Count())
+		}
+		for pos := Position(0); pos < 64; pos++ {
+			if attacked.IsSet(pos) != fen.SquareControl.AttacksSquare(color, pos) {
+				t.Errorf("Expecting AttackedSquares to agree with AttacksSquare on %s for %s", pos, color)
+			}
+		}
+	}
+	if attacked := fen.AttackedSquares(White); attacked.IsSet(A1) || attacked.IsSet(H1) || !attacked.IsSet(H3) {
+		t.Errorf("Expecting white to attack h3, but not a1 and h1")
+	}
+}
+
+func Benchmark_SquareControl_GetCaptures(b *testing.B) {
+	fen, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fen.SquareControl.GetCaptures(White, fen.Board)
+	}
+}
+
+func Benchmark_ValidMovesList_captures(b *testing.B) {
+	fen, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		captures := []*Move{}
+		for _, move := range fen.validMoves.ToMoves(White, fen.Pieces, fen.Board) {
+			if fen.Board.IsOpposingPiece(move.To, White) {
+				captures = append(captures, move)
+			}
+		}
+	}
+}
@@ -12,6 +12,12 @@ type EngineOption uint8
 
 const (
 	SELDEPTH EngineOption = iota
+	FULLWIDTH
+	// In milliseconds
+	MOVETIME
+	// In half moves
+	RANDOMOPENING
+	RANDOMSEED
 )
 
 type Engine interface {
@@ -84,7 +90,16 @@ func (uci *UCI) Start(reader *bufio.Reader) {
 			case "quit":
 				return
 			case "go":
-				if cmdParts[1] == "infinite" {
+				// Only "go movetime" limits the time
+				movetime := 0
+				if cmdParts[1] == "movetime" {
+					movetime, err = strconv.Atoi(cmdParts[2])
+					if err != nil {
+						panic(err)
+					}
+				}
+				uci.Engine.SetOption(MOVETIME, movetime)
+				if cmdParts[1] == "infinite" || cmdParts[1] == "movetime" {
 					uci.Engine.Start(engineOutput, -1, -1)
 				} else if cmdParts[1] == "nodes" {
 					nodes, err := strconv.Atoi(cmdParts[2])
@@ -110,22 +125,9 @@ func (uci *UCI) Start(reader *bufio.Reader) {
 				uci.Engine.Stop()
 				break
 			case "position":
-				if cmdParts[1] == "fen" {
-					fenStr := strings.Join(cmdParts[2:], " ")
-					fen, err := ParseFEN(fenStr)
-					if err != nil {
-						log.Write([]byte("Error parsing fen: " + err.Error()))
-						return
-					}
-					uci.Engine.SetPosition(fen)
-				} else if cmdParts[1] == "startpos" {
-					fenStr := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
-					fen, err := ParseFEN(fenStr)
-					if err != nil {
-						log.Write([]byte("Error parsing fen: " + err.Error()))
-						return
-					}
-					uci.Engine.SetPosition(fen)
+				if err := uci.setPosition(cmdParts[1:]); err != nil {
+					log.Write([]byte("Error setting position: " + err.Error()))
+					return
 				}
 			}
 		case out := <-engineOutput:
@@ -134,3 +136,49 @@ func (uci *UCI) Start(reader *bufio.Reader) {
 		}
 	}
 }
+
+// Handles the arguments of the "position" command, which are either
+// "startpos" or "fen" followed by a FEN string, optionally followed by
+// "moves" and the moves that were played since.
+func (uci *UCI) setPosition(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Missing position")
+	}
+	moves := []string{}
+	for i, arg := range args {
+		if arg == "moves" {
+			moves = args[i+1:]
+			args = args[:i]
+			break
+		}
+	}
+	fenStr := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	if args[0] == "fen" {
+		fenStr = strings.Join(args[1:], " ")
+	} else if args[0] != "startpos" {
+		return fmt.Errorf("Unknown position %s", args[0])
+	}
+	game, err := ParseFEN(fenStr)
+	if err != nil {
+		return err
+	}
+	for _, moveStr := range moves {
+		move, err := ParseMove(moveStr)
+		if err != nil {
+			return err
+		}
+		// Promotions are sent in lower case, so they're parsed as black
+		// pieces.
+		if move.Promote != NoPiece {
+			move = NewPromotionMove(move.From, move.To, move.Promote.SetColor(game.ToMove))
+		}
+		if !game.IsLegalMove(move) {
+			return fmt.Errorf("Illegal move %s in %s", moveStr, game.FENString())
+		}
+		game = game.ApplyMove(move)
+	}
+	// The moves are kept in the Parent games, so repetitions are still
+	// found, but the search starts with an empty Line.
+	uci.Engine.SetPosition(game.AsSearchRoot())
+	return nil
+}
@@ -0,0 +1,75 @@
+package chess_engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_UCI_position_fen_with_moves(t *testing.T) {
+	engine := NewBSEngine(2)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	unit := NewUCI("bs-engine", "test", engine)
+	fen := "r3k2r/pppq1ppp/2n2n2/2b5/3p4/2N2N2/PPPPPPPP/R1BQKB1R w KQkq - 4 8"
+	if err := unit.setPosition(strings.Fields("fen " + fen + " moves e2e4")); err != nil {
+		t.Fatal(err)
+	}
+	position := engine.GetPosition()
+	expected := "r3k2r/pppq1ppp/2n2n2/2b5/3pP3/2N2N2/PPPP1PPP/R1BQKB1R b KQkq e3 0 8"
+	if position.FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, position.FENString())
+	}
+	if len(position.Line) != 0 || position.Parent == nil {
+		t.Errorf("Expecting the search to start without a line, but with the game so far")
+	}
+	bestmove := getBestMove(engine, time.Second)
+	move, err := ParseMove(bestmove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !position.IsLegalMove(move) {
+		t.Errorf("Expecting a legal move for black, got %s", bestmove)
+	}
+}
+
+func Test_UCI_position_promotes_to_the_movers_colour(t *testing.T) {
+	engine := NewBSEngine(2)
+	unit := NewUCI("bs-engine", "test", engine)
+	if err := unit.setPosition(strings.Fields("fen 8/4P3/8/8/8/8/k3p3/7K w - - 0 1 moves e7e8q e2e1n")); err != nil {
+		t.Fatal(err)
+	}
+	expected := "4Q3/8/8/8/8/8/k7/4n2K w - - 0 2"
+	if engine.GetPosition().FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, engine.GetPosition().FENString())
+	}
+}
+
+func Test_UCI_position_startpos_with_moves(t *testing.T) {
+	engine := NewBSEngine(2)
+	unit := NewUCI("bs-engine", "test", engine)
+	if err := unit.setPosition([]string{"startpos", "moves", "e2e4", "e7e5", "g1f3"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"
+	if engine.GetPosition().FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, engine.GetPosition().FENString())
+	}
+	if err := unit.setPosition([]string{"startpos"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	if engine.GetPosition().FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, engine.GetPosition().FENString())
+	}
+	for _, args := range [][]string{
+		{},
+		{"nowhere"},
+		{"fen", "8/8/8"},
+		{"startpos", "moves", "e2e5"},
+		{"startpos", "moves", "e2"},
+	} {
+		if err := unit.setPosition(args); err == nil {
+			t.Errorf("Expecting an error for %v", args)
+		}
+	}
+}
@@ -121,8 +121,9 @@ func (v ValidMovesList) extendPreviouslyBlockedPieces(moveFrom Position, board B
 				// TODO: en passant?
 
 			case Knight:
-				// We can safely skip knight moves
-				break
+				// Knights don't move along lines, so a knight on the line
+				// can't be extended. Knights that can jump to the vacated
+				// square are handled below.
 
 			case King:
 
@@ -235,6 +236,10 @@ func (v ValidMovesList) shrinkValidMovesForPiecesThatAreNowBlocked(moveTo Positi
 	}
 	// extend knights
 	color := board[moveTo].OppositeColor()
+	if color == NoColor {
+		// There's nothing on the square to capture or to be blocked by
+		return
+	}
 	for _, pos := range moveTo.GetKnightMoves() {
 		if board[pos] == Knight.ToPiece(color) {
 			v[pos] = v[pos].Add(moveTo)
@@ -245,7 +250,7 @@ func (v ValidMovesList) shrinkValidMovesForPiecesThatAreNowBlocked(moveTo Positi
 
 }
 
-func (v ValidMovesList) ApplyMove(move *Move, movingPiece Piece, board Board, enPassantVulnerable Position, knownPieces PiecePositions) ValidMovesList {
+func (v ValidMovesList) ApplyMove(move *Move, movingPiece Piece, board Board, enPassantVulnerable, newEnPassantVulnerable Position, knownPieces PiecePositions) ValidMovesList {
 
 	// Copy current validmoves
 	result := v.Copy()
@@ -277,9 +282,38 @@ func (v ValidMovesList) ApplyMove(move *Move, movingPiece Piece, board Board, en
 	if castles != nil {
 		result.AddPiece(Rook.ToPiece(movingPiece.Color()), castles.To, board)
 	}
+
+	// The previous en passant capture is no longer possible, but if this
+	// move was a pawn's opening jump the opponent may now capture it.
+	if enPassantVulnerable != NoPosition {
+		result.RemoveEnPassantCaptures(enPassantVulnerable, movingPiece.Color(), board)
+	}
+	if newEnPassantVulnerable != NoPosition {
+		result.AddEnPassantCaptures(newEnPassantVulnerable, movingPiece.OppositeColor(), board)
+	}
 	return result
 }
 
+// Adds the capture onto @enpassantSquare for every pawn of @color that is
+// attacking it.
+func (v ValidMovesList) AddEnPassantCaptures(enpassantSquare Position, color Color, board Board) {
+	for _, pos := range enpassantSquare.GetPawnAttacks(color.Opposite()) {
+		if board[pos] == Pawn.ToPiece(color) {
+			v[pos] = v[pos].Add(enpassantSquare)
+		}
+	}
+}
+
+// Removes the capture onto @enpassantSquare for every pawn of @color that is
+// attacking it.
+func (v ValidMovesList) RemoveEnPassantCaptures(enpassantSquare Position, color Color, board Board) {
+	for _, pos := range enpassantSquare.GetPawnAttacks(color.Opposite()) {
+		if board[pos] == Pawn.ToPiece(color) {
+			v[pos] = v[pos].Remove(enpassantSquare)
+		}
+	}
+}
+
 func (v ValidMovesList) addLineUntilBlockingPiece(fromPos Position, line []Position, board Board, color Color) {
 	for _, toPos := range line {
 		if board.IsEmpty(toPos) {
@@ -0,0 +1,124 @@
+package chess_engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// XBoard implements a minimal version of the Chess Engine Communication
+// Protocol (CECP), which is spoken by xboard, Winboard and other older GUIs.
+type XBoard struct {
+	Engine Engine
+
+	// The position in the game that is currently being played
+	Game *Game
+
+	// In force mode the engine only records the moves it receives, without
+	// playing any moves itself.
+	Force bool
+}
+
+func NewXBoard(engine Engine) *XBoard {
+	return &XBoard{
+		Engine: engine,
+	}
+}
+
+func (x *XBoard) Run(in io.Reader, out io.Writer) error {
+	if err := x.newGame(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		cmdParts := strings.Fields(scanner.Text())
+		if len(cmdParts) == 0 {
+			continue
+		}
+		switch cmdParts[0] {
+		case "protover":
+			fmt.Fprintln(out, "feature myname=\"bs-engine\" usermove=1 setboard=1 done=1")
+		case "new":
+			if err := x.newGame(); err != nil {
+				return err
+			}
+			x.Force = false
+		case "force":
+			x.Force = true
+		case "go":
+			x.Force = false
+			x.play(out)
+		case "usermove":
+			if len(cmdParts) != 2 {
+				fmt.Fprintln(out, "Error (expecting a move): "+scanner.Text())
+				continue
+			}
+			move, err := ParseMove(cmdParts[1])
+			if err != nil || !x.isValidMove(move) {
+				fmt.Fprintln(out, "Illegal move: "+cmdParts[1])
+				continue
+			}
+			x.Game = x.Game.ApplyMove(move)
+			if !x.Force {
+				x.play(out)
+			}
+		case "setboard":
+			game, err := ParseFEN(strings.Join(cmdParts[1:], " "))
+			if err != nil {
+				fmt.Fprintln(out, "tellusererror Illegal position: "+err.Error())
+				continue
+			}
+			x.Game = game
+		case "quit":
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func (x *XBoard) newGame() error {
+	game, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		return err
+	}
+	x.Game = game
+	return nil
+}
+
+// Promotion pieces are always sent in lowercase so we have to compare them
+// without looking at the colour.
+func (x *XBoard) isValidMove(move *Move) bool {
+	for _, valid := range x.Game.ValidMoves() {
+		if valid.From == move.From && valid.To == move.To {
+			if valid.Promote == NoPiece || valid.Promote.ToNormalizedPiece() == move.Promote.ToNormalizedPiece() {
+				move.Promote = valid.Promote
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Searches the current position and plays the best move.
+func (x *XBoard) play(out io.Writer) {
+	if x.Game.IsFinished() {
+		return
+	}
+	output := make(chan string, 50)
+	x.Engine.SetPosition(x.Game.AsSearchRoot())
+	x.Engine.Start(output, -1, -1)
+	for line := range output {
+		if !strings.HasPrefix(line, "bestmove ") {
+			continue
+		}
+		move, err := ParseMove(strings.Fields(line)[1])
+		if err != nil || !x.isValidMove(move) {
+			fmt.Fprintln(out, "tellusererror Engine played an illegal move: "+line)
+			return
+		}
+		x.Game = x.Game.ApplyMove(move)
+		fmt.Fprintln(out, "move "+move.String())
+		return
+	}
+}
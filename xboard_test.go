@@ -0,0 +1,61 @@
+package chess_engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func runXBoard(t *testing.T, script string) (*XBoard, []string) {
+	engine := NewBSEngine(2)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	unit := NewXBoard(engine)
+	out := bytes.NewBuffer([]byte{})
+	if err := unit.Run(strings.NewReader(script), out); err != nil {
+		t.Fatal(err)
+	}
+	return unit, strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+func Test_XBoard_replies_to_usermove(t *testing.T) {
+	unit, output := runXBoard(t, "xboard\nnew\nusermove e2e4\nquit\n")
+	if len(output) != 1 || !strings.HasPrefix(output[0], "move ") {
+		t.Fatalf("Expecting a move reply, got %v", output)
+	}
+	if len(unit.Game.Line) != 2 || unit.Game.Line[0].String() != "e2e4" {
+		t.Errorf("Expecting e2e4 and a reply in the game, got %v", unit.Game.Line)
+	}
+	if unit.Game.Line[1].String() != output[0][5:] {
+		t.Errorf("Expecting %s to be played, got %s", output[0][5:], unit.Game.Line[1])
+	}
+	if unit.Game.ToMove != White {
+		t.Errorf("Expecting white to move")
+	}
+}
+
+func Test_XBoard_force_and_go(t *testing.T) {
+	unit, output := runXBoard(t, "new\nforce\nusermove e2e4\nusermove e7e5\ngo\nquit\n")
+	if len(output) != 1 || !strings.HasPrefix(output[0], "move ") {
+		t.Fatalf("Expecting only one move reply, got %v", output)
+	}
+	if len(unit.Game.Line) != 3 || unit.Game.ToMove != Black {
+		t.Errorf("Expecting white to have replied to e2e4 e7e5, got %v", unit.Game.Line)
+	}
+}
+
+func Test_XBoard_setboard(t *testing.T) {
+	_, output := runXBoard(t, "force\nsetboard 8/8/8/qn6/kn6/1n6/1KP5/8 w - - 0 1\ngo\nquit\n")
+	if len(output) != 1 || output[0] != "move c2b3" {
+		t.Errorf("Expecting mate in one, got %v", output)
+	}
+}
+
+func Test_XBoard_illegal_move(t *testing.T) {
+	unit, output := runXBoard(t, "new\nforce\nusermove e2e5\nquit\n")
+	if len(output) != 1 || output[0] != "Illegal move: e2e5" {
+		t.Errorf("Expecting an illegal move error, got %v", output)
+	}
+	if len(unit.Game.Line) != 0 {
+		t.Errorf("Not expecting any moves to be played")
+	}
+}
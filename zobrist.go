@@ -0,0 +1,60 @@
+package chess_engine
+
+import (
+	"math/rand"
+)
+
+// The seed used to generate the Zobrist keys. It is fixed so that hashes are
+// the same across runs, which makes them usable in tests and debug output.
+const ZobristSeed = 20200612
+
+var (
+	zobristPieces      [12][64]uint64
+	zobristCastling    [2][4]uint64
+	zobristEnPassant   [8]uint64
+	zobristBlackToMove uint64
+)
+
+func init() {
+	rng := rand.New(rand.NewSource(ZobristSeed))
+	for piece := range zobristPieces {
+		for pos := range zobristPieces[piece] {
+			zobristPieces[piece][pos] = rng.Uint64()
+		}
+	}
+	for color := range zobristCastling {
+		for cs := range zobristCastling[color] {
+			if CastleStatus(cs) != None {
+				zobristCastling[color][cs] = rng.Uint64()
+			}
+		}
+	}
+	for file := range zobristEnPassant {
+		zobristEnPassant[file] = rng.Uint64()
+	}
+	zobristBlackToMove = rng.Uint64()
+}
+
+// Returns the Zobrist hash of the position. The move clocks are not part of
+// the hash.
+func (f *Game) Hash() uint64 {
+	if f.hash != 0 {
+		return f.hash
+	}
+	hash := uint64(0)
+	for pos, piece := range f.Board {
+		if piece != NoPiece {
+			hash ^= zobristPieces[piece][pos]
+		}
+	}
+	hash ^= zobristCastling[White][f.CastleStatuses.White]
+	hash ^= zobristCastling[Black][f.CastleStatuses.Black]
+	if f.EnPassantVulnerable != NoPosition {
+		hash ^= zobristEnPassant[f.EnPassantVulnerable.GetFile()-FileA]
+	}
+	if f.ToMove == Black {
+		hash ^= zobristBlackToMove
+	}
+	f.hash = hash
+	return hash
+}
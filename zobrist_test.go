@@ -0,0 +1,81 @@
+package chess_engine
+
+import "testing"
+
+func Test_Hash_is_stable(t *testing.T) {
+	cases := map[string]uint64{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1":     0x892e9f5f796e5d3e,
+		"rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1": 0xca597c03fd90c4f6,
+	}
+	for fenStr, expected := range cases {
+		fen, err := ParseFEN(fenStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fen.Hash() != expected {
+			t.Errorf("Expecting hash %#x for %s, got %#x", expected, fenStr, fen.Hash())
+		}
+	}
+}
+
+func Test_Hash_ignores_move_clocks(t *testing.T) {
+	fen, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := fen.ApplyMove(MustParseMove("g1f3")).ApplyMove(MustParseMove("g8f6"))
+	next = next.ApplyMove(MustParseMove("f3g1")).ApplyMove(MustParseMove("f6g8"))
+	if next.Hash() != fen.Hash() {
+		t.Errorf("Expecting the same hash after returning to the start position, got %#x and %#x", fen.Hash(), next.Hash())
+	}
+	next = fen.ApplyMove(MustParseMove("e2e4"))
+	if next.Hash() == fen.Hash() {
+		t.Errorf("Expecting a different hash after e2e4")
+	}
+}
+
+func Test_Hash_after_promotions(t *testing.T) {
+	fen, err := ParseFEN("8/P7/7k/8/8/8/8/4K1N1 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	play := func(moves ...*Move) *Game {
+		game := fen
+		for _, move := range moves {
+			game = game.ApplyMove(move)
+		}
+		return game
+	}
+	queen := NewPromotionMove(A7, A8, WhiteQueen)
+	rook := NewPromotionMove(A7, A8, WhiteRook)
+	promoteFirst := play(queen, MustParseMove("h6h5"), MustParseMove("g1f3"), MustParseMove("h5g6"))
+	promoteLast := play(MustParseMove("g1f3"), MustParseMove("h6h5"), queen, MustParseMove("h5g6"))
+	underpromote := play(rook, MustParseMove("h6h5"), MustParseMove("g1f3"), MustParseMove("h5g6"))
+
+	expected, err := ParseFEN("Q7/8/6k1/8/8/5N2/8/4K3 w - - 0 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if promoteFirst.Hash() != expected.Hash() {
+		t.Errorf("Expecting hash %#x after promoting first, got %#x", expected.Hash(), promoteFirst.Hash())
+	}
+	if promoteLast.Hash() != expected.Hash() {
+		t.Errorf("Expecting hash %#x after promoting last, got %#x", expected.Hash(), promoteLast.Hash())
+	}
+	if underpromote.Hash() == expected.Hash() {
+		t.Errorf("Expecting a different hash after promoting to a rook")
+	}
+
+	// A cache keyed by the hash would return the score of whichever
+	// position was evaluated first, so they'd better be the same.
+	evaluators := Evaluators{NaiveMaterialEvaluator, SpaceEvaluator, MobilityEvaluator}
+	firstScore, _ := evaluators.Eval(promoteFirst)
+	lastScore, _ := evaluators.Eval(promoteLast)
+	rookScore, _ := evaluators.Eval(underpromote)
+	if firstScore != lastScore {
+		t.Errorf("Expecting the same score for both move orders, got %d and %d", firstScore, lastScore)
+	}
+	if rookScore == firstScore {
+		t.Errorf("Expecting a different score after promoting to a rook, got %d", rookScore)
+	}
+}